@@ -27,14 +27,30 @@ func main() {
 
 	mgr := sessions.NewManager(cfg)
 
+	// The server subscribes push/webhook listeners, so build it before
+	// recovery to let them see recovered and exited events.
+	srv := handler.NewServer(cfg, mgr)
+
 	// Recover existing sessions from tmux
 	if err := mgr.Recover(); err != nil {
 		log.Printf("Warning: session recovery: %v", err)
 	}
 
+	// Background status monitor, inspection of panes for agent_state and
+	// the idle_timeout/max_lifetime reaper, started once the recovered
+	// sessions are in place
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	if cfg.TmuxControlMode {
+		go mgr.RunControlMode(bgCtx)
+	}
+	go mgr.RunMonitor(bgCtx)
+	go mgr.RunAgentStateDetector(bgCtx)
+	go mgr.RunReaper(bgCtx)
+
 	httpSrv := &http.Server{
 		Addr:    cfg.ListenAddr,
-		Handler: handler.NewServer(cfg, mgr),
+		Handler: srv,
 	}
 
 	// Graceful shutdown
@@ -43,6 +59,7 @@ func main() {
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 		sig := <-sigCh
 		log.Printf("Received %v, shutting down...", sig)
+		stopBackground()
 
 		// Give in-flight requests up to 10 seconds to complete
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
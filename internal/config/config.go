@@ -2,12 +2,98 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Terminal backends for the embedded web terminal at /t/{id}/.
+const (
+	TerminalTtyd    = "ttyd"    // external ttyd process per session, reverse-proxied
+	TerminalBuiltin = "builtin" // tmux client under a PTY, bridged over WebSocket by the gateway
+)
+
+// tmuxOptionPattern matches tmux option names, including user options (@name).
+var tmuxOptionPattern = regexp.MustCompile(`^@?[a-z][a-z0-9-]*$`)
+
+// Multiplexer backends hosting the sessions.
+const (
+	MultiplexerTmux   = "tmux"
+	MultiplexerScreen = "screen" // GNU screen, for hosts without tmux
+)
+
+// AgentStateRule maps a regular expression over the bottom of a session's
+// screen to an agent state. Rules are tried in order; the first match wins.
+type AgentStateRule struct {
+	State   string `yaml:"state"`
+	Pattern string `yaml:"pattern"`
+}
+
+// AgentStates lists the states an AgentStateRule may assign.
+var AgentStates = []string{"working", "idle", "awaiting_approval", "errored"}
+
+// DefaultAgentStateRules recognise the Claude Code CLI UI.
+var DefaultAgentStateRules = []AgentStateRule{
+	{State: "awaiting_approval", Pattern: `Do you want to|❯ 1\. Yes|\(y/n\)`},
+	{State: "working", Pattern: `(?i)esc to interrupt`},
+	{State: "errored", Pattern: `(?i)API Error|Error: .*(overloaded|rate.?limit)`},
+	{State: "idle", Pattern: `(?m)^\s*(│\s*)?>\s`},
+}
+
+// WebhookConfig is an outgoing webhook for session events.
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+	// Events filters which event types are sent; empty means all.
+	Events []string `yaml:"events"`
+	// Secret keys the HMAC-SHA256 signature header; empty disables signing.
+	Secret string `yaml:"secret"`
+}
+
+// ProjectEnv is the default environment for sessions created in Path or
+// below it.
+type ProjectEnv struct {
+	Path string            `yaml:"path"`
+	Env  map[string]string `yaml:"env"`
+}
+
+// Template is a named session setup, listed at GET /api/templates and
+// used with POST /api/sessions {"template": name}. Fields given in the
+// request override the template's.
+type Template struct {
+	Name     string            `yaml:"name" json:"name"`
+	CWD      string            `yaml:"cwd" json:"cwd"`
+	StartCmd string            `yaml:"start_cmd" json:"start_cmd,omitempty"`
+	Env      map[string]string `yaml:"env" json:"-"` // values may be secrets; never sent to clients
+	Tags     []string          `yaml:"tags" json:"tags,omitempty"`
+	// Prompt is typed into the session once its program is ready for input.
+	Prompt string `yaml:"prompt" json:"prompt,omitempty"`
+}
+
+// templateNamePattern matches template names.
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// DefaultEnvDenied lists variables sessions may not be given through the
+// API: they change how programs are loaded or how the shell starts.
+var DefaultEnvDenied = []string{
+	"LD_*", "DYLD_*", "PATH", "HOME", "SHELL", "USER", "TMUX", "TMUX_*",
+	"BASH_ENV", "ENV", "PROMPT_COMMAND", "IFS",
+}
+
+// envNamePattern matches valid environment variable names.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// WebhookEvents lists the event types a webhook can subscribe to.
+var WebhookEvents = []string{
+	"created", "killed", "exited", "recovered", "restarted", "updated",
+	"agent_state", "timeout_warning", "timed_out",
+}
+
 type Config struct {
 	ListenAddr      string   `yaml:"listen_addr"`
 	ProjectsAllowed []string `yaml:"projects_allowed"`
@@ -18,6 +104,63 @@ type Config struct {
 	AuthToken       string   `yaml:"auth_token"`
 	MaxSessions     int      `yaml:"max_sessions"`
 	SessionsFile    string   `yaml:"sessions_file"`
+	StreamBufferKB  int      `yaml:"stream_buffer_kb"`
+	TerminalBackend string   `yaml:"terminal_backend"`
+	Multiplexer     string   `yaml:"multiplexer"`
+
+	// TmuxSocket runs sessions on the gateway's own tmux server: a socket
+	// name (tmux -L) or, if it contains "/", a socket path (tmux -S).
+	TmuxSocket string `yaml:"tmux_socket"`
+	// TmuxConfig is sourced by the dedicated server instead of ~/.tmux.conf.
+	TmuxConfig string `yaml:"tmux_config"`
+	// TmuxOptions are tmux options set on new sessions, e.g. history-limit.
+	TmuxOptions    map[string]string `yaml:"tmux_options"`
+	RecordSessions bool              `yaml:"record_sessions"`
+	RecordingsDir  string            `yaml:"recordings_dir"`
+
+	PushEnabled bool   `yaml:"push_enabled"`
+	PushSubject string `yaml:"push_subject"`
+
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+
+	AgentStateRules    []AgentStateRule `yaml:"agent_state_rules"`
+	AgentStateInterval time.Duration    `yaml:"agent_state_interval"`
+
+	// MonitorInterval is how often session status is refreshed from tmux.
+	MonitorInterval time.Duration `yaml:"monitor_interval"`
+	// TmuxControlMode sends tmux commands over one `tmux -C` connection
+	// instead of spawning a process per command. It only applies with
+	// TmuxSocket, so no helper session is added to the default server.
+	TmuxControlMode bool `yaml:"tmux_control_mode"`
+	// FollowLatestClient sizes tmux windows to the most recently active
+	// client (window-size latest) instead of the smallest attached one.
+	// Off by default, as it changes how the user's own clients are sized.
+	FollowLatestClient bool `yaml:"follow_latest_client"`
+
+	// EnvAllowed and EnvDenied filter the env a session may be created
+	// with (see IsEnvAllowed). ProjectEnv adds per-project defaults, which
+	// are not filtered.
+	EnvAllowed []string     `yaml:"env_allowed"`
+	EnvDenied  []string     `yaml:"env_denied"`
+	ProjectEnv []ProjectEnv `yaml:"project_env"`
+
+	// UploadDir is where POST /api/sessions/{id}/upload stores files,
+	// relative to the session's working directory.
+	UploadDir string `yaml:"upload_dir"`
+	// UploadMaxMB is the size limit per uploaded file.
+	UploadMaxMB int `yaml:"upload_max_mb"`
+
+	// IdleTimeout stops sessions without output or input for this long,
+	// and MaxLifetime those older than this; zero disables either. Both
+	// can be overridden per session. TimeoutGrace is the time between the
+	// warning, the interrupt and the kill.
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	MaxLifetime  time.Duration `yaml:"max_lifetime"`
+	TimeoutGrace time.Duration `yaml:"timeout_grace"`
+
+	// Templates are named session setups; their env is not filtered by
+	// env_allowed/env_denied.
+	Templates []Template `yaml:"templates"`
 }
 
 func Load(path string) (*Config, error) {
@@ -27,12 +170,28 @@ func Load(path string) (*Config, error) {
 	}
 
 	cfg := &Config{
-		ListenAddr:   "127.0.0.1:8787",
-		TmuxPrefix:   "claude-",
-		TtydBasePort: 9000,
-		TtydMaxPort:  9099,
-		MaxSessions:  10,
-		SessionsFile: "sessions.json",
+		ListenAddr:      "127.0.0.1:8787",
+		TmuxPrefix:      "claude-",
+		TtydBasePort:    9000,
+		TtydMaxPort:     9099,
+		MaxSessions:     10,
+		SessionsFile:    "sessions.json",
+		StreamBufferKB:  256,
+		TerminalBackend: TerminalTtyd,
+		Multiplexer:     MultiplexerTmux,
+		RecordingsDir:   "recordings",
+
+		PushEnabled: true,
+		PushSubject: "mailto:cc-web@localhost",
+
+		AgentStateRules:    DefaultAgentStateRules,
+		AgentStateInterval: 3 * time.Second,
+		MonitorInterval:    2 * time.Second,
+		TmuxControlMode:    true,
+		UploadDir:          ".cc-web/uploads",
+		UploadMaxMB:        25,
+		TimeoutGrace:       5 * time.Minute,
+		EnvDenied:          DefaultEnvDenied,
 	}
 
 	if err := yaml.Unmarshal(data, cfg); err != nil {
@@ -47,36 +206,183 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("ttyd_base_port (%d) must be <= ttyd_max_port (%d)", cfg.TtydBasePort, cfg.TtydMaxPort)
 	}
 
+	if cfg.TerminalBackend != TerminalTtyd && cfg.TerminalBackend != TerminalBuiltin {
+		return nil, fmt.Errorf("terminal_backend must be %q or %q", TerminalTtyd, TerminalBuiltin)
+	}
+	if cfg.Multiplexer != MultiplexerTmux && cfg.Multiplexer != MultiplexerScreen {
+		return nil, fmt.Errorf("multiplexer must be %q or %q", MultiplexerTmux, MultiplexerScreen)
+	}
+	for name := range cfg.TmuxOptions {
+		if !tmuxOptionPattern.MatchString(name) {
+			return nil, fmt.Errorf("tmux_options: invalid option name %q", name)
+		}
+	}
+
+	for i, rule := range cfg.AgentStateRules {
+		if !slices.Contains(AgentStates, rule.State) {
+			return nil, fmt.Errorf("agent_state_rules[%d]: state must be one of %v", i, AgentStates)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return nil, fmt.Errorf("agent_state_rules[%d]: %w", i, err)
+		}
+	}
+	for i, wh := range cfg.Webhooks {
+		u, err := url.Parse(wh.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhooks[%d]: url must be an absolute http(s) URL", i)
+		}
+		for _, e := range wh.Events {
+			if !slices.Contains(WebhookEvents, e) {
+				return nil, fmt.Errorf("webhooks[%d]: unknown event %q (valid: %v)", i, e, WebhookEvents)
+			}
+		}
+	}
+
+	for i, pattern := range append(append([]string(nil), cfg.EnvAllowed...), cfg.EnvDenied...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("env_allowed/env_denied[%d]: bad pattern %q", i, pattern)
+		}
+	}
+	for i, pe := range cfg.ProjectEnv {
+		if pe.Path == "" {
+			return nil, fmt.Errorf("project_env[%d]: path is required", i)
+		}
+		for name := range pe.Env {
+			if !envNamePattern.MatchString(name) {
+				return nil, fmt.Errorf("project_env[%d]: invalid variable name %q", i, name)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, tpl := range cfg.Templates {
+		if !templateNamePattern.MatchString(tpl.Name) || seen[tpl.Name] {
+			return nil, fmt.Errorf("templates[%d]: name %q must be unique and made of letters, digits and . _ -", i, tpl.Name)
+		}
+		seen[tpl.Name] = true
+		if tpl.CWD == "" {
+			return nil, fmt.Errorf("templates[%d]: cwd is required", i)
+		}
+		for name := range tpl.Env {
+			if !envNamePattern.MatchString(name) {
+				return nil, fmt.Errorf("templates[%d]: invalid variable name %q", i, name)
+			}
+		}
+	}
+
+	if dir := filepath.Clean(cfg.UploadDir); filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("upload_dir must be a relative path inside the session directory")
+	}
+	if cfg.UploadMaxMB <= 0 {
+		return nil, fmt.Errorf("upload_max_mb must be positive")
+	}
+
+	if cfg.IdleTimeout < 0 || cfg.MaxLifetime < 0 {
+		return nil, fmt.Errorf("idle_timeout and max_lifetime must not be negative")
+	}
+	if cfg.TimeoutGrace <= 0 {
+		return nil, fmt.Errorf("timeout_grace must be positive")
+	}
+
+	if cfg.AgentStateInterval <= 0 {
+		return nil, fmt.Errorf("agent_state_interval must be positive")
+	}
+	if cfg.MonitorInterval <= 0 {
+		return nil, fmt.Errorf("monitor_interval must be positive")
+	}
+
+	if cfg.StreamBufferKB <= 0 {
+		return nil, fmt.Errorf("stream_buffer_kb must be positive")
+	}
+
 	return cfg, nil
 }
 
 func (c *Config) IsPathAllowed(path string) bool {
-	abs, err := filepath.Abs(path)
-	if err != nil {
+	abs, ok := resolvePath(path)
+	if !ok {
 		return false
 	}
-	// Try to resolve symlinks; fall back to cleaned abs path if path doesn't exist yet
-	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
-		abs = resolved
+	for _, allowed := range c.ProjectsAllowed {
+		if allowedAbs, ok := resolvePath(allowed); ok && pathWithin(abs, allowedAbs) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEnvAllowed reports whether a session may be given the environment
+// variable name: it must match env_allowed (when set) and not env_denied.
+// Patterns use filepath.Match syntax, e.g. "ANTHROPIC_*".
+func (c *Config) IsEnvAllowed(name string) bool {
+	if !envNamePattern.MatchString(name) || matchesAny(c.EnvDenied, name) {
+		return false
 	}
-	abs = filepath.Clean(abs)
+	return len(c.EnvAllowed) == 0 || matchesAny(c.EnvAllowed, name)
+}
 
-	for _, allowed := range c.ProjectsAllowed {
-		allowedAbs, err := filepath.Abs(allowed)
-		if err != nil {
-			continue
+// ProjectEnvFor returns the default environment for sessions in dir: the
+// env of every project_env entry whose path contains dir, deeper paths
+// overriding shallower ones.
+func (c *Config) ProjectEnvFor(dir string) map[string]string {
+	env := map[string]string{}
+	abs, ok := resolvePath(dir)
+	if !ok {
+		return env
+	}
+	var matches []ProjectEnv
+	for _, pe := range c.ProjectEnv {
+		if p, ok := resolvePath(pe.Path); ok && pathWithin(abs, p) {
+			matches = append(matches, ProjectEnv{Path: p, Env: pe.Env})
 		}
-		if resolved, err := filepath.EvalSymlinks(allowedAbs); err == nil {
-			allowedAbs = resolved
+	}
+	slices.SortStableFunc(matches, func(a, b ProjectEnv) int { return len(a.Path) - len(b.Path) })
+	for _, pe := range matches {
+		for k, v := range pe.Env {
+			env[k] = v
 		}
-		allowedAbs = filepath.Clean(allowedAbs)
+	}
+	return env
+}
 
-		// Check if abs is equal to or under allowedAbs
-		if abs == allowedAbs {
-			return true
+// Template returns the template with the given name.
+func (c *Config) Template(name string) (Template, bool) {
+	for _, tpl := range c.Templates {
+		if tpl.Name == name {
+			return tpl, true
 		}
-		prefix := allowedAbs + string(filepath.Separator)
-		if len(abs) > len(prefix) && abs[:len(prefix)] == prefix {
+	}
+	return Template{}, false
+}
+
+// resolvePath returns the absolute, cleaned form of path with symlinks
+// resolved; a path that doesn't exist yet is only made absolute.
+func resolvePath(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	return filepath.Clean(abs), true
+}
+
+// pathWithin reports whether path is dir or under it; both must come from resolvePath.
+func pathWithin(path, dir string) bool {
+	if path == dir {
+		return true
+	}
+	prefix := dir + string(filepath.Separator)
+	if dir == string(filepath.Separator) {
+		prefix = dir
+	}
+	return strings.HasPrefix(path, prefix)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
 			return true
 		}
 	}
@@ -106,3 +106,122 @@ func TestIsPathAllowed(t *testing.T) {
 		}
 	}
 }
+
+func TestLoad_InvalidAgentStateRule(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+
+	for _, rules := range []string{
+		"agent_state_rules:\n  - state: \"sleeping\"\n    pattern: \"zzz\"\n",
+		"agent_state_rules:\n  - state: \"idle\"\n    pattern: \"(\"\n",
+	} {
+		content := "auth_token: \"test-secret-token-123\"\n" + rules
+		if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(cfgPath); err == nil {
+			t.Errorf("expected error for rules:\n%s", rules)
+		}
+	}
+}
+
+func TestLoad_InvalidWebhook(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+
+	for _, hooks := range []string{
+		"webhooks:\n  - url: \"/relative\"\n",
+		"webhooks:\n  - url: \"ftp://example.com/hook\"\n",
+		"webhooks:\n  - url: \"https://example.com/hook\"\n    events: [\"deleted\"]\n",
+	} {
+		content := "auth_token: \"test-secret-token-123\"\n" + hooks
+		if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(cfgPath); err == nil {
+			t.Errorf("expected error for webhooks:\n%s", hooks)
+		}
+	}
+}
+
+func TestLoad_InvalidTmuxOption(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	content := "auth_token: \"test-secret-token-123\"\ntmux_options:\n  \"history-limit; run-shell x\": \"1\"\n"
+	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil {
+		t.Error("expected error for invalid tmux option name")
+	}
+}
+
+func TestEnvAllowed(t *testing.T) {
+	cfg := &Config{EnvAllowed: []string{"ANTHROPIC_*", "HTTPS_PROXY", "LD_PRELOAD"}, EnvDenied: DefaultEnvDenied}
+	tests := map[string]bool{
+		"ANTHROPIC_MODEL": true,
+		"HTTPS_PROXY":     true,
+		"HTTP_PROXY":      false,
+		"LD_PRELOAD":      false, // denied wins
+		"ANTHROPIC-X":     false,
+	}
+	for name, want := range tests {
+		if got := cfg.IsEnvAllowed(name); got != want {
+			t.Errorf("IsEnvAllowed(%q) = %v, want %v", name, got, want)
+		}
+	}
+	cfg.EnvAllowed = nil
+	if !cfg.IsEnvAllowed("HTTP_PROXY") || cfg.IsEnvAllowed("PATH") {
+		t.Error("empty env_allowed should allow everything not denied")
+	}
+}
+
+func TestProjectEnvFor(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "app")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{ProjectEnv: []ProjectEnv{
+		{Path: sub, Env: map[string]string{"MODEL": "opus", "APP": "1"}},
+		{Path: root, Env: map[string]string{"MODEL": "sonnet", "ROOT": "1"}},
+	}}
+	env := cfg.ProjectEnvFor(sub)
+	if env["MODEL"] != "opus" || env["APP"] != "1" || env["ROOT"] != "1" {
+		t.Errorf("ProjectEnvFor(sub) = %v", env)
+	}
+	if env := cfg.ProjectEnvFor(root); env["MODEL"] != "sonnet" || env["APP"] != "" {
+		t.Errorf("ProjectEnvFor(root) = %v", env)
+	}
+}
+
+func TestLoad_Templates(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	content := `auth_token: "test-secret-token-123"
+templates:
+  - name: "backend-review"
+    cwd: "/tmp"
+    start_cmd: "claude"
+    tags: ["backend"]
+    prompt: "Review the open PR"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl, ok := cfg.Template("backend-review"); !ok || tpl.CWD != "/tmp" || tpl.Prompt != "Review the open PR" {
+		t.Errorf("template = %+v, %v", tpl, ok)
+	}
+
+	dup := content + "  - name: \"backend-review\"\n    cwd: \"/tmp\"\n"
+	if err := os.WriteFile(cfgPath, []byte(dup), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(cfgPath); err == nil {
+		t.Error("expected error for duplicate template name")
+	}
+}
@@ -0,0 +1,214 @@
+package http
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// ansiPalette holds the 16 standard xterm colors used for SGR 30–37/90–97.
+var ansiPalette = [16]string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// sgrState tracks the text attributes set by SGR escape sequences.
+type sgrState struct {
+	fg, bg                                string
+	bold, dim, italic, underline, inverse bool
+}
+
+// style returns the inline CSS for the current attributes ("" when plain).
+func (s sgrState) style() string {
+	fg, bg := s.fg, s.bg
+	if s.inverse {
+		fg, bg = bg, fg
+		if fg == "" {
+			fg = "var(--screen-bg, #000)"
+		}
+		if bg == "" {
+			bg = "var(--screen-fg, #fff)"
+		}
+	}
+	var parts []string
+	if fg != "" {
+		parts = append(parts, "color:"+fg)
+	}
+	if bg != "" {
+		parts = append(parts, "background:"+bg)
+	}
+	if s.bold {
+		parts = append(parts, "font-weight:bold")
+	}
+	if s.dim {
+		parts = append(parts, "opacity:0.6")
+	}
+	if s.italic {
+		parts = append(parts, "font-style:italic")
+	}
+	if s.underline {
+		parts = append(parts, "text-decoration:underline")
+	}
+	return strings.Join(parts, ";")
+}
+
+// apply updates the state from the numeric parameters of one SGR sequence.
+func (s *sgrState) apply(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == 0:
+			*s = sgrState{}
+		case p == 1:
+			s.bold = true
+		case p == 2:
+			s.dim = true
+		case p == 3:
+			s.italic = true
+		case p == 4:
+			s.underline = true
+		case p == 7:
+			s.inverse = true
+		case p == 22:
+			s.bold, s.dim = false, false
+		case p == 23:
+			s.italic = false
+		case p == 24:
+			s.underline = false
+		case p == 27:
+			s.inverse = false
+		case p >= 30 && p <= 37:
+			s.fg = ansiPalette[p-30]
+		case p == 39:
+			s.fg = ""
+		case p >= 40 && p <= 47:
+			s.bg = ansiPalette[p-40]
+		case p == 49:
+			s.bg = ""
+		case p >= 90 && p <= 97:
+			s.fg = ansiPalette[p-90+8]
+		case p >= 100 && p <= 107:
+			s.bg = ansiPalette[p-100+8]
+		case p == 38 || p == 48:
+			color, n := extendedColor(params[i+1:])
+			i += n
+			if p == 38 {
+				s.fg = color
+			} else {
+				s.bg = color
+			}
+		}
+	}
+}
+
+// extendedColor parses the tail of a 38/48 sequence (5;n or 2;r;g;b) and
+// returns the CSS color plus the number of parameters consumed.
+func extendedColor(params []int) (string, int) {
+	if len(params) >= 2 && params[0] == 5 {
+		return color256(params[1]), 2
+	}
+	if len(params) >= 4 && params[0] == 2 {
+		return fmt.Sprintf("#%02x%02x%02x", clampByte(params[1]), clampByte(params[2]), clampByte(params[3])), 4
+	}
+	return "", len(params)
+}
+
+// color256 converts an xterm 256-color index to a CSS hex color.
+func color256(n int) string {
+	switch {
+	case n < 0 || n > 255:
+		return ""
+	case n < 16:
+		return ansiPalette[n]
+	case n < 232:
+		n -= 16
+		levels := [6]int{0, 95, 135, 175, 215, 255}
+		return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[(n/6)%6], levels[n%6])
+	default:
+		v := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", v, v, v)
+	}
+}
+
+func clampByte(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
+
+// ansiToHTML renders terminal output containing SGR escape sequences as an
+// HTML <pre> block with inline-styled spans. Other escape sequences are dropped.
+func ansiToHTML(in string) string {
+	var b strings.Builder
+	var st sgrState
+	open := false
+
+	b.WriteString(`<pre class="screen">`)
+	for i := 0; i < len(in); {
+		if in[i] != 0x1b {
+			j := strings.IndexByte(in[i:], 0x1b)
+			if j < 0 {
+				j = len(in) - i
+			}
+			b.WriteString(html.EscapeString(in[i : i+j]))
+			i += j
+			continue
+		}
+
+		// ESC [ params final — CSI sequence
+		if i+1 < len(in) && in[i+1] == '[' {
+			j := i + 2
+			for j < len(in) && (in[j] < 0x40 || in[j] > 0x7e) {
+				j++
+			}
+			if j >= len(in) {
+				break
+			}
+			if in[j] == 'm' {
+				if open {
+					b.WriteString("</span>")
+					open = false
+				}
+				st.apply(parseSGRParams(in[i+2 : j]))
+				if css := st.style(); css != "" {
+					fmt.Fprintf(&b, `<span style="%s">`, css)
+					open = true
+				}
+			}
+			i = j + 1
+			continue
+		}
+		// Any other escape: skip ESC and the following byte
+		i += 2
+	}
+	if open {
+		b.WriteString("</span>")
+	}
+	b.WriteString("</pre>")
+	return b.String()
+}
+
+// parseSGRParams splits "1;31" into [1 31]. Empty fields count as 0.
+func parseSGRParams(s string) []int {
+	if s == "" {
+		return nil
+	}
+	fields := strings.Split(strings.ReplaceAll(s, ":", ";"), ";")
+	params := make([]int, 0, len(fields))
+	for _, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			n = 0
+		}
+		params = append(params, n)
+	}
+	return params
+}
@@ -0,0 +1,24 @@
+package http
+
+import "testing"
+
+func TestANSIToHTML(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"plain", `<pre class="screen">plain</pre>`},
+		{"a<b>&c", `<pre class="screen">a&lt;b&gt;&amp;c</pre>`},
+		{"\x1b[31mred\x1b[0m ok", `<pre class="screen"><span style="color:#cd0000">red</span> ok</pre>`},
+		{"\x1b[1;38;5;196mx", `<pre class="screen"><span style="color:#ff0000;font-weight:bold">x</span></pre>`},
+		{"\x1b[48;2;1;2;3my\x1b[m", `<pre class="screen"><span style="background:#010203">y</span></pre>`},
+		{"\x1b[2Kclear\x1b[?25l", `<pre class="screen">clear</pre>`},
+	}
+
+	for _, tt := range tests {
+		got := ansiToHTML(tt.input)
+		if got != tt.expected {
+			t.Errorf("ansiToHTML(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
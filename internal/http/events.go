@@ -0,0 +1,97 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/cc-web/internal/sessions"
+)
+
+// handleEvents serves GET /api/events, an SSE feed of session events.
+// Optional filters: session_id=<id> and type=<t1>,<t2>,... Each event's
+// id is its sequence number, so a reconnecting client resumes with
+// Last-Event-ID. A "reset" event means events were missed and the client
+// should re-fetch /api/sessions.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	q := r.URL.Query()
+	sessionID := q.Get("session_id")
+	types := make(map[sessions.EventType]bool)
+	if v := q.Get("type"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			typ := sessions.EventType(strings.TrimSpace(t))
+			if !slices.Contains(sessions.EventTypes, typ) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown event type: " + string(typ)})
+				return
+			}
+			types[typ] = true
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+
+	after := s.mgr.LastEventSeq()
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid Last-Event-ID"})
+			return
+		}
+		after = n
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepalive := time.NewTicker(sseKeepalive)
+	defer keepalive.Stop()
+	for {
+		events, complete, wait := s.mgr.EventsSince(after)
+		if !complete {
+			after = s.mgr.LastEventSeq()
+			writeSSE(w, "reset", after, map[string]interface{}{"seq": after})
+			flusher.Flush()
+			continue
+		}
+		sent := false
+		for _, ev := range events {
+			after = ev.Seq
+			if sessionID != "" && ev.SessionID != sessionID {
+				continue
+			}
+			if len(types) > 0 && !types[ev.Type] {
+				continue
+			}
+			writeSSE(w, string(ev.Type), ev.Seq, ev)
+			sent = true
+		}
+		if sent {
+			flusher.Flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			io.WriteString(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-wait:
+		}
+	}
+}
@@ -4,22 +4,48 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/user/cc-web/internal/config"
+	"github.com/user/cc-web/internal/push"
 	"github.com/user/cc-web/internal/sessions"
+	"github.com/user/cc-web/internal/webhooks"
 )
 
+// History paging limits for GET /api/sessions/{id}/history.
+const (
+	defaultHistoryLines = 200
+	maxHistoryLines     = 5000
+)
+
+// maxTerminalSize bounds cols and rows for POST /api/sessions/{id}/resize.
+const maxTerminalSize = 1000
+
+// sseKeepalive is how often an idle event stream sends a comment line, so
+// proxies such as Cloudflare Tunnel do not close the connection.
+const sseKeepalive = 15 * time.Second
+
+// recordingFollowInterval is how often a followed recording is checked for new events.
+const recordingFollowInterval = 500 * time.Millisecond
+
 type Server struct {
-	cfg     *config.Config
-	mgr     *sessions.Manager
-	mux     *http.ServeMux
+	cfg   *config.Config
+	mgr   *sessions.Manager
+	mux   *http.ServeMux
+	push  *push.Service        // nil when Web Push is disabled
+	hooks *webhooks.Dispatcher // nil when no webhooks are configured
 }
 
 func NewServer(cfg *config.Config, mgr *sessions.Manager) *Server {
@@ -28,6 +54,16 @@ func NewServer(cfg *config.Config, mgr *sessions.Manager) *Server {
 		mgr: mgr,
 		mux: http.NewServeMux(),
 	}
+	if cfg.PushEnabled {
+		s.push = newPushService(cfg)
+		if s.push != nil {
+			mgr.Subscribe(s.notifyPush)
+		}
+	}
+	if len(cfg.Webhooks) > 0 {
+		s.hooks = webhooks.NewDispatcher(cfg.Webhooks)
+		mgr.Subscribe(s.hooks.Handle)
+	}
 	s.routes()
 	return s
 }
@@ -43,6 +79,10 @@ func (s *Server) routes() {
 	// API routes (auth required)
 	s.mux.HandleFunc("/api/sessions", s.authMiddleware(s.handleSessions))
 	s.mux.HandleFunc("/api/sessions/", s.authMiddleware(s.handleSessionAction))
+	s.mux.HandleFunc("/api/events", s.authMiddleware(s.handleEvents))
+	s.mux.HandleFunc("/api/templates", s.authMiddleware(s.handleTemplates))
+	s.mux.HandleFunc("/api/push/", s.authMiddleware(s.handlePush))
+	s.mux.HandleFunc("/api/webhooks/deliveries", s.authMiddleware(s.handleWebhookDeliveries))
 
 	// Terminal proxy (auth via cookie for WebSocket/iframe)
 	s.mux.HandleFunc("/t/", s.authTerminal(s.handleTerminalProxy))
@@ -100,8 +140,12 @@ func (s *Server) authTerminal(next http.HandlerFunc) http.HandlerFunc {
 func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		list := s.mgr.List()
-		writeJSON(w, http.StatusOK, list)
+		q, err := parseListQuery(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, s.mgr.List(q))
 
 	case http.MethodPost:
 		var req sessions.CreateRequest
@@ -109,11 +153,12 @@ func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
 			return
 		}
-		if req.Name == "" {
+		// A template supplies both
+		if req.Name == "" && req.Template == "" {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name is required"})
 			return
 		}
-		if req.CWD == "" {
+		if req.CWD == "" && req.Template == "" {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cwd is required"})
 			return
 		}
@@ -131,6 +176,30 @@ func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// parseListQuery reads the filters of GET /api/sessions: status, tag (may
+// repeat), cwd_prefix, q and sort.
+func parseListQuery(r *http.Request) (sessions.ListQuery, error) {
+	v := r.URL.Query()
+	q := sessions.ListQuery{
+		Status:    sessions.Status(v.Get("status")),
+		Tags:      v["tag"],
+		CWDPrefix: v.Get("cwd_prefix"),
+		Text:      v.Get("q"),
+		Sort:      v.Get("sort"),
+	}
+	switch q.Status {
+	case "", sessions.StatusRunning, sessions.StatusExited, sessions.StatusUnknown:
+	default:
+		return q, fmt.Errorf("status must be running, exited or unknown")
+	}
+	switch q.Sort {
+	case "", sessions.SortCreatedAt, sessions.SortLastSeenAt, sessions.SortName:
+	default:
+		return q, fmt.Errorf("sort must be created_at, last_seen_at or name")
+	}
+	return q, nil
+}
+
 // handleSessionAction handles /api/sessions/{id}/... routes
 func (s *Server) handleSessionAction(w http.ResponseWriter, r *http.Request) {
 	// Parse path: /api/sessions/{id} or /api/sessions/{id}/{action}
@@ -146,28 +215,77 @@ func (s *Server) handleSessionAction(w http.ResponseWriter, r *http.Request) {
 	if len(parts) > 1 {
 		action = parts[1]
 	}
+	// Sub-resources: /api/sessions/{id}/recordings/{name}, /api/sessions/{id}/windows/...
+	if strings.HasPrefix(action, "recordings/") {
+		s.handleRecordingFile(w, r, id, strings.TrimPrefix(action, "recordings/"))
+		return
+	}
+	if action == "windows" || strings.HasPrefix(action, "windows/") {
+		s.handleWindows(w, r, id, strings.TrimPrefix(strings.TrimPrefix(action, "windows"), "/"))
+		return
+	}
 
 	switch action {
 	case "":
-		// GET /api/sessions/{id}
-		if r.Method != http.MethodGet {
+		switch r.Method {
+		case http.MethodGet:
+			sess, ok := s.mgr.Get(id)
+			if !ok {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+				return
+			}
+			writeJSON(w, http.StatusOK, sess)
+
+		case http.MethodPatch:
+			var req sessions.MetadataUpdate
+			if err := readJSON(r, &req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+				return
+			}
+			sess, err := s.mgr.UpdateMetadata(id, req)
+			if err != nil {
+				writeSessionError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, sess)
+
+		default:
+			w.Header().Set("Allow", "GET, PATCH")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+
+	case "send":
+		if r.Method != http.MethodPost {
 			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 			return
 		}
-		sess, ok := s.mgr.Get(id)
-		if !ok {
-			writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		var req struct {
+			Text   string `json:"text"`
+			Target string `json:"target"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
 			return
 		}
-		writeJSON(w, http.StatusOK, sess)
+		if req.Text == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "text is required"})
+			return
+		}
+		if err := s.mgr.SendText(id, req.Target, req.Text); err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "sent"})
 
-	case "send":
+	case "paste":
 		if r.Method != http.MethodPost {
 			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 			return
 		}
 		var req struct {
-			Text string `json:"text"`
+			Text   string `json:"text"`
+			Enter  bool   `json:"enter"`
+			Target string `json:"target"`
 		}
 		if err := readJSON(r, &req); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
@@ -177,18 +295,28 @@ func (s *Server) handleSessionAction(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "text is required"})
 			return
 		}
-		if err := s.mgr.SendText(id, req.Text); err != nil {
+		if err := s.mgr.Paste(id, req.Target, req.Text, req.Enter); err != nil {
 			writeSessionError(w, err)
 			return
 		}
-		writeJSON(w, http.StatusOK, map[string]string{"status": "sent"})
+		writeJSON(w, http.StatusOK, map[string]string{"status": "pasted"})
 
 	case "interrupt":
 		if r.Method != http.MethodPost {
 			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
 			return
 		}
-		if err := s.mgr.Interrupt(id); err != nil {
+		// The body is optional: {"target": "1.0"}
+		var req struct {
+			Target string `json:"target"`
+		}
+		if r.ContentLength != 0 {
+			if err := readJSON(r, &req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+				return
+			}
+		}
+		if err := s.mgr.Interrupt(id, req.Target); err != nil {
 			writeSessionError(w, err)
 			return
 		}
@@ -200,7 +328,8 @@ func (s *Server) handleSessionAction(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		var req struct {
-			Keys []string `json:"keys"`
+			Keys   []string `json:"keys"`
+			Target string   `json:"target"`
 		}
 		if err := readJSON(r, &req); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
@@ -210,12 +339,145 @@ func (s *Server) handleSessionAction(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "keys is required"})
 			return
 		}
-		if err := s.mgr.SendKeys(id, req.Keys); err != nil {
+		if err := s.mgr.SendKeys(id, req.Target, req.Keys); err != nil {
 			writeSessionError(w, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, map[string]string{"status": "keys sent"})
 
+	case "upload":
+		s.handleUpload(w, r, id)
+
+	case "resize":
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		var req struct {
+			Cols   int    `json:"cols"`
+			Rows   int    `json:"rows"`
+			Target string `json:"target"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return
+		}
+		if req.Cols < 1 || req.Cols > maxTerminalSize || req.Rows < 1 || req.Rows > maxTerminalSize {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("cols and rows must be between 1 and %d", maxTerminalSize)})
+			return
+		}
+		sess, err := s.mgr.Resize(id, req.Target, req.Cols, req.Rows)
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, sess)
+
+	case "screen":
+		// GET /api/sessions/{id}/screen?format=text|ansi|html
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "text"
+		}
+		if format != "text" && format != "ansi" && format != "html" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "format must be text, ansi or html"})
+			return
+		}
+		screen, err := s.mgr.CaptureScreen(id, format != "text")
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		if format == "html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			screen = ansiToHTML(screen)
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, screen)
+
+	case "history":
+		// GET /api/sessions/{id}/history?from=&lines=&q=
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		query := r.URL.Query()
+		q := sessions.HistoryQuery{From: -1, Lines: defaultHistoryLines}
+		if v := query.Get("from"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "from must be a non-negative integer"})
+				return
+			}
+			q.From = n
+		}
+		if v := query.Get("lines"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > maxHistoryLines {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("lines must be between 1 and %d", maxHistoryLines)})
+				return
+			}
+			q.Lines = n
+		}
+		if v := query.Get("q"); v != "" {
+			re, err := regexp.Compile(v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid q regex: " + err.Error()})
+				return
+			}
+			q.Pattern = re
+			// A search without an explicit offset starts from the oldest line
+			if q.From < 0 {
+				q.From = 0
+			}
+		}
+		hist, err := s.mgr.History(id, q)
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, hist)
+
+	case "stream":
+		// GET /api/sessions/{id}/stream — SSE feed of pane output
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		s.handleSessionStream(w, r, id)
+
+	case "recordings":
+		// GET /api/sessions/{id}/recordings
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		list, err := s.mgr.ListRecordings(id)
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+
+	case "restart":
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		sess, err := s.mgr.Restart(id)
+		if err != nil {
+			writeCreateError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, sess)
+
 	case "kill":
 		if r.Method != http.MethodPost {
 			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
@@ -232,7 +494,156 @@ func (s *Server) handleSessionAction(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleTerminalProxy proxies requests to the ttyd instance for a session.
+// handleSessionStream streams pane output as Server-Sent Events.
+// Each "output" event carries its sequence number as the SSE id, so a
+// reconnecting EventSource resumes via Last-Event-ID. A "snapshot" event with
+// the current screen is sent first, and again whenever the client fell too far
+// behind to be resumed without a gap.
+func (s *Server) handleSessionStream(w http.ResponseWriter, r *http.Request, id string) {
+	buf, err := s.mgr.OutputStream(id)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+
+	var after uint64
+	resume := false
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid Last-Event-ID"})
+			return
+		}
+		after, resume = n, true
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sendSnapshot := func() bool {
+		after = buf.LastSeq()
+		screen, err := s.mgr.CaptureScreen(id, true)
+		if err != nil {
+			log.Printf("stream %s: snapshot: %v", id, err)
+			return false
+		}
+		writeSSE(w, "snapshot", after, map[string]interface{}{"seq": after, "screen": screen})
+		flusher.Flush()
+		return true
+	}
+	if !resume && !sendSnapshot() {
+		return
+	}
+
+	keepalive := time.NewTicker(sseKeepalive)
+	defer keepalive.Stop()
+	for {
+		chunks, complete, wait := buf.Since(after)
+		if !complete {
+			if !sendSnapshot() {
+				return
+			}
+			continue
+		}
+		for _, c := range chunks {
+			writeSSE(w, "output", c.Seq, map[string]interface{}{"seq": c.Seq, "data": string(c.Data)})
+			after = c.Seq
+		}
+		if len(chunks) > 0 {
+			flusher.Flush()
+		}
+		if len(chunks) == 0 && buf.Closed() {
+			writeSSE(w, "end", after, map[string]interface{}{"seq": after})
+			flusher.Flush()
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			io.WriteString(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-wait:
+		}
+	}
+}
+
+// handleRecordingFile serves GET /api/sessions/{id}/recordings/{name}.
+// ?download=1 adds an attachment Content-Disposition. ?follow=1 on a recording
+// that is still being written keeps the response open and streams new events
+// as they are appended, until the recording ends or the client goes away.
+func (s *Server) handleRecordingFile(w http.ResponseWriter, r *http.Request, id, name string) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	path, active, err := s.mgr.RecordingFile(id, name)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	if r.URL.Query().Get("download") == "1" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+"-"+name))
+	}
+	if r.URL.Query().Get("follow") != "1" || !active {
+		info, err := f.Stat()
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		http.ServeContent(w, r, name, info.ModTime(), f)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming unsupported"})
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(recordingFollowInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := io.Copy(w, f); err != nil {
+			return
+		}
+		flusher.Flush()
+		if !active {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		// One more copy after the recorder finishes picks up its final events
+		if _, active, err = s.mgr.RecordingFile(id, name); err != nil {
+			return
+		}
+	}
+}
+
+// handleTerminalProxy proxies requests to the ttyd instance for a session,
+// or serves the built-in terminal when terminal_backend is "builtin".
 func (s *Server) handleTerminalProxy(w http.ResponseWriter, r *http.Request) {
 	// Path: /t/{session-id}/...
 	path := strings.TrimPrefix(r.URL.Path, "/t/")
@@ -243,6 +654,15 @@ func (s *Server) handleTerminalProxy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sessionID := parts[0]
+	if s.cfg.TerminalBackend == config.TerminalBuiltin {
+		rest := ""
+		if len(parts) > 1 {
+			rest = parts[1]
+		}
+		s.handleBuiltinTerminal(w, r, sessionID, rest)
+		return
+	}
+
 	port, ok := s.mgr.GetTtydPort(sessionID)
 	if !ok || port == 0 {
 		http.Error(w, "session not found or terminal unavailable", http.StatusNotFound)
@@ -282,7 +702,7 @@ func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sessionList := s.mgr.List()
+	sessionList := s.mgr.List(sessions.ListQuery{})
 	running := 0
 	for _, sess := range sessionList {
 		if sess.Status == sessions.StatusRunning {
@@ -301,6 +721,16 @@ func isWebSocket(r *http.Request) bool {
 	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
 }
 
+// writeSSE writes one Server-Sent Event with a JSON payload.
+func writeSSE(w io.Writer, event string, id uint64, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("sse: marshal %s event: %v", event, err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+}
+
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -328,9 +758,16 @@ func tokenMatch(provided, expected string) bool {
 // User errors (bad path, max sessions) get 400; internal errors (tmux/ttyd) get 500.
 func writeCreateError(w http.ResponseWriter, err error) {
 	msg := err.Error()
-	if strings.Contains(msg, "not in allowed list") ||
+	if sessions.IsNotFound(err) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": msg})
+	} else if errors.Is(err, sessions.ErrInvalidMetadata) || errors.Is(err, sessions.ErrUnknownTemplate) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
+	} else if errors.Is(err, sessions.ErrSessionRunning) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": msg})
+	} else if strings.Contains(msg, "not in allowed list") ||
 		strings.Contains(msg, "does not exist or is not a directory") ||
-		strings.Contains(msg, "max active sessions") {
+		strings.Contains(msg, "max active sessions") ||
+		strings.Contains(msg, "must not be negative") {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
 	} else {
 		log.Printf("create session error: %v", err)
@@ -341,10 +778,58 @@ func writeCreateError(w http.ResponseWriter, err error) {
 // writeSessionError maps session manager errors to appropriate HTTP status codes.
 // Internal errors are logged but not exposed to clients.
 func writeSessionError(w http.ResponseWriter, err error) {
-	if sessions.IsNotFound(err) {
+	switch {
+	case sessions.IsNotFound(err), errors.Is(err, sessions.ErrTargetNotFound):
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
-	} else {
+	case errors.Is(err, sessions.ErrInvalidTarget), errors.Is(err, sessions.ErrInvalidKey),
+		errors.Is(err, sessions.ErrUploadNotAllowed), errors.Is(err, sessions.ErrInvalidMetadata):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, sessions.ErrUploadTooLarge):
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+	case errors.Is(err, sessions.ErrSessionExited):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, sessions.ErrUnsupported):
+		writeJSON(w, http.StatusNotImplemented, map[string]string{"error": err.Error()})
+	default:
 		log.Printf("session error: %v", err)
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal server error"})
 	}
 }
+
+// handleTemplates handles GET /api/templates: the session templates from the config.
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	templates := make([]templateView, 0, len(s.cfg.Templates))
+	for _, tpl := range s.cfg.Templates {
+		names := make([]string, 0, len(tpl.Env))
+		for name := range tpl.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		templates = append(templates, templateView{Template: tpl, EnvNames: names})
+	}
+	writeJSON(w, http.StatusOK, templates)
+}
+
+// templateView is a template as listed by GET /api/templates: its env
+// values may be secrets, so only the variable names are included.
+type templateView struct {
+	config.Template
+	EnvNames []string `json:"env_names"`
+}
+
+// handleWebhookDeliveries returns the recent webhook delivery log, newest first.
+func (s *Server) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.hooks == nil {
+		writeJSON(w, http.StatusOK, []webhooks.Delivery{})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.hooks.Deliveries())
+}
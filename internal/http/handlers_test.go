@@ -1,15 +1,20 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/user/cc-web/internal/config"
 	"github.com/user/cc-web/internal/sessions"
+	"github.com/user/cc-web/internal/sessions/sessionstest"
 )
 
 func testConfig(t *testing.T) *config.Config {
@@ -192,3 +197,648 @@ func TestQueryParamRejectedOnAPI(t *testing.T) {
 		t.Errorf("status = %d, want %d (query param should be rejected on API)", w.Code, http.StatusUnauthorized)
 	}
 }
+
+func TestScreen_NotFound(t *testing.T) {
+	cfg := testConfig(t)
+	mgr := sessions.NewManager(cfg)
+	srv := NewServer(cfg, mgr)
+
+	req := httptest.NewRequest("GET", "/api/sessions/nonexistent/screen", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestScreen_BadFormat(t *testing.T) {
+	cfg := testConfig(t)
+	mgr := sessions.NewManager(cfg)
+	srv := NewServer(cfg, mgr)
+
+	req := httptest.NewRequest("GET", "/api/sessions/nonexistent/screen?format=pdf", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHistory_BadParams(t *testing.T) {
+	cfg := testConfig(t)
+	mgr := sessions.NewManager(cfg)
+	srv := NewServer(cfg, mgr)
+
+	for _, query := range []string{"from=-1", "lines=0", "lines=abc", "q=("} {
+		req := httptest.NewRequest("GET", "/api/sessions/nonexistent/history?"+query, nil)
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestStream_NotFound(t *testing.T) {
+	cfg := testConfig(t)
+	mgr := sessions.NewManager(cfg)
+	srv := NewServer(cfg, mgr)
+
+	req := httptest.NewRequest("GET", "/api/sessions/nonexistent/stream", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestRecordings_NotFound(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.RecordingsDir = t.TempDir()
+	mgr := sessions.NewManager(cfg)
+	srv := NewServer(cfg, mgr)
+
+	for _, path := range []string{
+		"/api/sessions/nonexistent/recordings",
+		"/api/sessions/nonexistent/recordings/20260101T000000Z.cast",
+		"/api/sessions/nonexistent/recordings/..%2F..%2Fsessions.json",
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestPushSubscriptions(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.PushEnabled = true
+	cfg.PushSubject = "mailto:test@example.com"
+	mgr := sessions.NewManager(cfg)
+	srv := NewServer(cfg, mgr)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/api/push/vapid-public-key", ""); w.Code != http.StatusOK {
+		t.Fatalf("vapid-public-key status = %d", w.Code)
+	}
+	if w := do("POST", "/api/push/subscriptions", `{"endpoint":"https://push.example/x","keys":{"p256dh":"bad","auth":"bad"}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid subscription status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	// Uncompressed P-256 generator point and a 16-byte auth secret
+	sub := `{"endpoint":"https://push.example/x","keys":{` +
+		`"p256dh":"BGsX0fLhLEJH-Lzm5WOkQPJ3A32BLeszoPShOUXYmMKWT-NC4v4af5uO5-tKfA-eFivOM1drMV7Oy7ZAaDe_UfU",` +
+		`"auth":"AAECAwQFBgcICQoLDA0ODw"}}`
+	if w := do("POST", "/api/push/subscriptions", sub); w.Code != http.StatusCreated {
+		t.Fatalf("subscribe status = %d: %s", w.Code, w.Body.String())
+	}
+	w := do("GET", "/api/push/subscriptions", "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "p256dh") || !strings.Contains(w.Body.String(), "push.example") {
+		t.Errorf("list = %d %s", w.Code, w.Body.String())
+	}
+	if w := do("DELETE", "/api/push/subscriptions", `{"endpoint":"https://push.example/x"}`); w.Code != http.StatusOK {
+		t.Errorf("unsubscribe status = %d", w.Code)
+	}
+}
+
+func TestEvents_BadParams(t *testing.T) {
+	cfg := testConfig(t)
+	mgr := sessions.NewManager(cfg)
+	srv := NewServer(cfg, mgr)
+
+	for _, tc := range []struct {
+		query, lastEventID string
+	}{
+		{"?type=created,bogus", ""},
+		{"", "abc"},
+	} {
+		req := httptest.NewRequest("GET", "/api/events"+tc.query, nil)
+		req.Header.Set("Authorization", "Bearer test-token")
+		if tc.lastEventID != "" {
+			req.Header.Set("Last-Event-ID", tc.lastEventID)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q/%q: status = %d, want %d", tc.query, tc.lastEventID, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSessionLifecycle_FakeMultiplexer(t *testing.T) {
+	cfg := testConfig(t)
+	fake := sessionstest.NewFakeMultiplexer()
+	mgr := sessions.NewManagerWithMultiplexer(cfg, fake)
+	srv := NewServer(cfg, mgr)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/sessions", `{"name":"demo","cwd":"/tmp","start_cmd":"cat"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var s sessions.Session
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do("POST", "/api/sessions/"+s.ID+"/send", `{"text":"hello"}`); w.Code != http.StatusOK {
+		t.Fatalf("send: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/sessions/"+s.ID+"/keys", `{"keys":["ESC","UP"]}`); w.Code != http.StatusOK {
+		t.Fatalf("keys: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/sessions/"+s.ID+"/interrupt", ``); w.Code != http.StatusOK {
+		t.Fatalf("interrupt: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/sessions/"+s.ID+"/paste", `{"text":"line 1\nline 2","enter":true}`); w.Code != http.StatusOK {
+		t.Fatalf("paste: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/sessions/"+s.ID+"/keys", `{"keys":["ESC","hello"]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown key: status = %d, want 400", w.Code)
+	}
+	input := strings.Join(fake.Input(s.TmuxName), ",")
+	if input != "hello,Escape,Up,C-c,line 1\nline 2,Enter" {
+		t.Errorf("input = %q", input)
+	}
+
+	w = do("GET", "/api/sessions/"+s.ID+"/screen", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "hello") {
+		t.Errorf("screen: status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	if w := do("POST", "/api/sessions/"+s.ID+"/kill", ""); w.Code != http.StatusOK {
+		t.Fatalf("kill: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if names, _ := fake.ListSessions(); len(names) != 0 {
+		t.Errorf("sessions left after kill: %v", names)
+	}
+}
+
+func TestWindows_UnsupportedBackend(t *testing.T) {
+	cfg := testConfig(t)
+	fake := sessionstest.NewFakeMultiplexer()
+	mgr := sessions.NewManagerWithMultiplexer(cfg, fake)
+	srv := NewServer(cfg, mgr)
+	sess, err := mgr.Create(sessions.CreateRequest{Name: "demo", CWD: "/tmp", StartCmd: "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"GET", "/api/sessions/" + sess.ID + "/windows", "", http.StatusNotImplemented},
+		{"POST", "/api/sessions/" + sess.ID + "/send", `{"text":"hi","target":"1.0"}`, http.StatusNotImplemented},
+		{"POST", "/api/sessions/" + sess.ID + "/send", `{"text":"hi","target":"top"}`, http.StatusBadRequest},
+		{"POST", "/api/sessions/" + sess.ID + "/windows", `{"cwd":"/etc"}`, http.StatusBadRequest},
+		{"GET", "/api/sessions/nonexistent/windows", "", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s %s %s: status = %d, want %d", tc.method, tc.path, tc.body, w.Code, tc.want)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	cfg := testConfig(t)
+	mgr := sessions.NewManagerWithMultiplexer(cfg, sessionstest.NewFakeMultiplexer())
+	srv := NewServer(cfg, mgr)
+	sess, err := mgr.Create(sessions.CreateRequest{Name: "demo", CWD: "/tmp", StartCmd: "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Cols != 80 || sess.Rows != 24 {
+		t.Errorf("initial size = %dx%d, want 80x24", sess.Cols, sess.Rows)
+	}
+
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		{`{"cols":0,"rows":40}`, http.StatusBadRequest},
+		{`{"cols":50,"rows":5000}`, http.StatusBadRequest},
+		{`{"cols":50,"rows":40,"target":"x"}`, http.StatusBadRequest},
+		{`{"cols":50,"rows":40}`, http.StatusOK},
+	} {
+		req := httptest.NewRequest("POST", "/api/sessions/"+sess.ID+"/resize", strings.NewReader(tc.body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != tc.code {
+			t.Errorf("%s: status = %d, want %d (body %s)", tc.body, w.Code, tc.code, w.Body.String())
+		}
+	}
+	if got, _ := mgr.Get(sess.ID); got.Cols != 50 || got.Rows != 40 {
+		t.Errorf("size after resize = %dx%d, want 50x40", got.Cols, got.Rows)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.UploadDir = ".cc-web/uploads"
+	cfg.UploadMaxMB = 1
+	fake := sessionstest.NewFakeMultiplexer()
+	mgr := sessions.NewManagerWithMultiplexer(cfg, fake)
+	srv := NewServer(cfg, mgr)
+	sess, err := mgr.Create(sessions.CreateRequest{Name: "demo", CWD: t.TempDir(), StartCmd: "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(query string, files map[string]string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for name, content := range files {
+			fw, err := mw.CreateFormFile("file", name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fw.Write([]byte(content))
+		}
+		mw.Close()
+		req := httptest.NewRequest("POST", "/api/sessions/"+sess.ID+"/upload"+query, &body)
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := upload("?type=1", map[string]string{"../../screen shot.png": "PNG"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var ups []sessions.Upload
+	if err := json.NewDecoder(w.Body).Decode(&ups); err != nil {
+		t.Fatal(err)
+	}
+	if len(ups) != 1 || filepath.Dir(ups[0].Path) != filepath.Join(sess.CWD, ".cc-web/uploads") ||
+		!strings.HasSuffix(ups[0].Name, "-screen_shot.png") {
+		t.Fatalf("uploads = %+v", ups)
+	}
+	if data, err := os.ReadFile(ups[0].Path); err != nil || string(data) != "PNG" {
+		t.Errorf("saved file = %q, %v", data, err)
+	}
+	if input := fake.Input(sess.TmuxName); len(input) != 1 || input[0] != ups[0].Path+" " {
+		t.Errorf("typed input = %q", input)
+	}
+
+	if w := upload("", map[string]string{"big.log": strings.Repeat("x", 1<<20+1)}); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload: status = %d, want 413", w.Code)
+	}
+	// Files saved before an oversized one are removed with it
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, f := range []struct{ name, content string }{{"small.txt", "ok"}, {"big.log", strings.Repeat("x", 1<<20+1)}} {
+		fw, err := mw.CreateFormFile("file", f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(f.content))
+	}
+	mw.Close()
+	req := httptest.NewRequest("POST", "/api/sessions/"+sess.ID+"/upload", &body)
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("partly oversized upload: status = %d, want 413", w.Code)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(sess.CWD, ".cc-web/uploads")); len(entries) != 1 {
+		t.Errorf("upload dir after a failed upload = %v, want only the first upload", entries)
+	}
+	if w := upload("", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("empty upload: status = %d, want 400", w.Code)
+	}
+
+	// A symlink in the upload path is not followed out of the session dir
+	outside := t.TempDir()
+	if err := os.RemoveAll(filepath.Join(sess.CWD, ".cc-web")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(sess.CWD, ".cc-web")); err != nil {
+		t.Fatal(err)
+	}
+	if w := upload("", map[string]string{"a.txt": "a"}); w.Code != http.StatusBadRequest {
+		t.Errorf("upload through a symlink: status = %d, want 400", w.Code)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("created outside the session dir: %v", entries)
+	}
+}
+
+func TestCreateSession_Env(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.EnvAllowed = []string{"ANTHROPIC_*"}
+	cfg.ProjectEnv = []config.ProjectEnv{{Path: "/tmp", Env: map[string]string{"ANTHROPIC_MODEL": "sonnet", "NO_COLOR": "1"}}}
+	mgr := sessions.NewManagerWithMultiplexer(cfg, sessionstest.NewFakeMultiplexer())
+	srv := NewServer(cfg, mgr)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/sessions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := create(`{"name":"env","cwd":"/tmp","start_cmd":"cat","env":{"ANTHROPIC_MODEL":"opus"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "opus") {
+		t.Errorf("response includes the env values: %s", w.Body.String())
+	}
+	var s sessions.Session
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := mgr.Get(s.ID); got.Env["ANTHROPIC_MODEL"] != "opus" || got.Env["NO_COLOR"] != "1" {
+		t.Errorf("env = %v", got.Env)
+	}
+
+	if w := create(`{"name":"env","cwd":"/tmp","env":{"LD_PRELOAD":"/x.so"}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("denied variable: status = %d, want 400", w.Code)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	cfg := testConfig(t)
+	fake := sessionstest.NewFakeMultiplexer()
+	mgr := sessions.NewManagerWithMultiplexer(cfg, fake)
+	srv := NewServer(cfg, mgr)
+	sess, err := mgr.Create(sessions.CreateRequest{Name: "demo", CWD: "/tmp", StartCmd: "cat", Env: map[string]string{"FOO": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restart := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/sessions/"+id+"/restart", nil)
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := restart(sess.ID); w.Code != http.StatusConflict {
+		t.Errorf("restart running session: status = %d, want 409", w.Code)
+	}
+	if w := restart("test-nope"); w.Code != http.StatusNotFound {
+		t.Errorf("restart unknown session: status = %d, want 404", w.Code)
+	}
+
+	fake.Exit(sess.TmuxName)
+	if err := mgr.Recover(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := mgr.Get(sess.ID); got.Status != sessions.StatusExited {
+		t.Fatalf("status after exit = %s", got.Status)
+	}
+	req := httptest.NewRequest("GET", "/api/sessions/"+sess.ID+"/stream", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("stream of exited session: status = %d, want 409", w.Code)
+	}
+
+	w = restart(sess.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("restart: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var got sessions.Session
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != sess.ID || got.Status != sessions.StatusRunning {
+		t.Errorf("restarted session = %+v", got)
+	}
+	// Env is kept in the sessions file across Recover
+	if got, _ := mgr.Get(sess.ID); got.Env["FOO"] != "1" {
+		t.Errorf("env after restart = %v", got.Env)
+	}
+	if names, _ := fake.ListSessions(); len(names) != 1 || names[0] != sess.TmuxName {
+		t.Errorf("fake sessions = %v, want [%s]", names, sess.TmuxName)
+	}
+}
+
+func TestUpdateMetadata(t *testing.T) {
+	cfg := testConfig(t)
+	mgr := sessions.NewManagerWithMultiplexer(cfg, sessionstest.NewFakeMultiplexer())
+	srv := NewServer(cfg, mgr)
+	sess, err := mgr.Create(sessions.CreateRequest{Name: "fix auth bug", CWD: "/tmp", StartCmd: "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patch := func(id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/sessions/"+id, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := patch(sess.ID, `{"name":" auth bug: waiting on review ","notes":"PR #12","color":"#FFAA00","emoji":"🔐","pinned":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("patch: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	got, _ := mgr.Get(sess.ID)
+	if got.Name != "auth bug: waiting on review" || got.Notes != "PR #12" || got.Color != "#ffaa00" || got.Emoji != "🔐" || !got.Pinned {
+		t.Errorf("session = %+v", got)
+	}
+	if got.TmuxName != sess.TmuxName {
+		t.Errorf("tmux name changed to %q", got.TmuxName)
+	}
+
+	// Omitted fields are kept
+	if w := patch(sess.ID, `{"pinned":false}`); w.Code != http.StatusOK {
+		t.Fatalf("unpin: status = %d", w.Code)
+	}
+	if got, _ := mgr.Get(sess.ID); got.Pinned || got.Notes != "PR #12" {
+		t.Errorf("after unpin = %+v", got)
+	}
+
+	for _, body := range []string{`{"name":"  "}`, `{"color":"red"}`, `{"emoji":"a b"}`} {
+		if w := patch(sess.ID, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, w.Code)
+		}
+	}
+	if w := patch("test-nope", `{"name":"x"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown session: status = %d, want 404", w.Code)
+	}
+}
+
+func TestListSessions_FilterAndSort(t *testing.T) {
+	cfg := testConfig(t)
+	mgr := sessions.NewManagerWithMultiplexer(cfg, sessionstest.NewFakeMultiplexer())
+	srv := NewServer(cfg, mgr)
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "api")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []sessions.CreateRequest{
+		{Name: "beta", CWD: sub, Tags: []string{"Backend", "review"}},
+		{Name: "Alpha", CWD: dir, Tags: []string{"backend"}},
+		{Name: "gamma", CWD: "/tmp"},
+	} {
+		req.StartCmd = "cat"
+		if _, err := mgr.Create(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(query string) ([]string, int) {
+		req := httptest.NewRequest("GET", "/api/sessions?"+query, nil)
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		var got []sessions.Session
+		_ = json.NewDecoder(w.Body).Decode(&got)
+		names := make([]string, len(got))
+		for i, s := range got {
+			names[i] = s.Name
+		}
+		return names, w.Code
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"sort=name", "Alpha,beta,gamma"},
+		{"tag=backend&sort=name", "Alpha,beta"},
+		{"tag=backend&tag=review", "beta"},
+		{"cwd_prefix=" + dir + "&sort=name", "Alpha,beta"},
+		{"cwd_prefix=" + sub, "beta"},
+		{"q=GAM", "gamma"},
+		{"q=review", "beta"},
+		{"status=exited", ""},
+	}
+	for _, tt := range tests {
+		names, code := list(tt.query)
+		if code != http.StatusOK || strings.Join(names, ",") != tt.want {
+			t.Errorf("%s: status %d, sessions %v, want %s", tt.query, code, names, tt.want)
+		}
+	}
+
+	// The default order is stable across calls
+	first, _ := list("")
+	for range 5 {
+		if again, _ := list(""); strings.Join(again, ",") != strings.Join(first, ",") {
+			t.Fatalf("order changed: %v then %v", first, again)
+		}
+	}
+
+	for _, query := range []string{"sort=size", "status=dead"} {
+		if _, code := list(query); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, code)
+		}
+	}
+	if _, err := mgr.Create(sessions.CreateRequest{Name: "bad", CWD: dir, Tags: []string{"has space"}}); err == nil {
+		t.Error("invalid tag accepted")
+	}
+}
+
+func TestCreateSession_Template(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Templates = []config.Template{{
+		Name:     "backend-review",
+		CWD:      "/tmp",
+		StartCmd: "claude --model opus",
+		Env:      map[string]string{"REVIEW": "1"},
+		Tags:     []string{"backend", "review"},
+		Prompt:   "Review the open PR",
+	}}
+	fake := sessionstest.NewFakeMultiplexer()
+	mgr := sessions.NewManagerWithMultiplexer(cfg, fake)
+	srv := NewServer(cfg, mgr)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("GET", "/api/templates", "")
+	if strings.Contains(w.Body.String(), `"1"`) {
+		t.Errorf("templates include env values: %s", w.Body.String())
+	}
+	var templates []templateView
+	if err := json.NewDecoder(w.Body).Decode(&templates); err != nil || len(templates) != 1 || templates[0].Name != "backend-review" ||
+		strings.Join(templates[0].EnvNames, ",") != "REVIEW" {
+		t.Fatalf("templates = %+v, %v", templates, err)
+	}
+
+	w = do("POST", "/api/sessions", `{"template":"backend-review","name":"pr 42","tags":["pr"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var s sessions.Session
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "pr 42" || s.CWD != "/tmp" || s.StartCmd != "claude --model opus" ||
+		s.Template != "backend-review" || strings.Join(s.Tags, ",") != "pr" {
+		t.Errorf("session = %+v", s)
+	}
+	if got, _ := mgr.Get(s.ID); got.Env["REVIEW"] != "1" {
+		t.Errorf("env = %v", got.Env)
+	}
+
+	// The prompt is sent once the program shows its input prompt
+	fake.SetScreen(s.TmuxName, "> ")
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if input := fake.Input(s.TmuxName); len(input) == 2 {
+			if input[0] != "Review the open PR" || input[1] != "Enter" {
+				t.Errorf("input = %v", input)
+			}
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if input := fake.Input(s.TmuxName); len(input) != 2 {
+		t.Errorf("initial prompt not sent: input = %v", input)
+	}
+
+	if w := do("POST", "/api/sessions", `{"template":"nope"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown template: status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,165 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/user/cc-web/internal/config"
+	"github.com/user/cc-web/internal/push"
+	"github.com/user/cc-web/internal/sessions"
+)
+
+// newPushService sets up Web Push with VAPID keys and subscriptions stored
+// next to sessions_file. Returns nil (push disabled) on failure.
+func newPushService(cfg *config.Config) *push.Service {
+	dir := filepath.Dir(cfg.SessionsFile)
+	svc, err := push.NewService(
+		filepath.Join(dir, "vapid_keys.json"),
+		filepath.Join(dir, "push_subscriptions.json"),
+		cfg.PushSubject,
+	)
+	if err != nil {
+		log.Printf("push: disabled: %v", err)
+		return nil
+	}
+	return svc
+}
+
+// notifyPush turns session events that need the user's attention into push
+// notifications. Runs as a sessions.Manager subscriber, so it only queues.
+func (s *Server) notifyPush(ev sessions.Event) {
+	name := ev.Session.Name
+	if name == "" {
+		name = ev.SessionID
+	}
+	n := push.Notification{SessionID: ev.SessionID, URL: "/", Tag: ev.SessionID}
+
+	switch ev.Type {
+	case sessions.EventExited:
+		n.Title = "Session exited"
+		n.Body = fmt.Sprintf("%s has exited.", name)
+	case sessions.EventTimeoutWarning:
+		n.Title = "Session will be stopped"
+		if ev.Detail == sessions.ReapMaxLifetime {
+			n.Body = fmt.Sprintf("%s reached its maximum lifetime and will be interrupted, then killed.", name)
+		} else {
+			n.Body = fmt.Sprintf("%s has been idle and will be interrupted, then killed, unless you use it.", name)
+		}
+	case sessions.EventTimedOut:
+		n.Title = "Session stopped"
+		if ev.Detail == sessions.ReapMaxLifetime {
+			n.Body = fmt.Sprintf("%s was killed after reaching its maximum lifetime.", name)
+		} else {
+			n.Body = fmt.Sprintf("%s was killed after being idle too long.", name)
+		}
+	case sessions.EventAgentState:
+		switch ev.Session.AgentState {
+		case sessions.AgentAwaitingApproval:
+			n.Title = "Approval needed"
+			n.Body = fmt.Sprintf("%s is waiting for permission.", name)
+		case sessions.AgentErrored:
+			n.Title = "Session error"
+			n.Body = fmt.Sprintf("%s reported an error.", name)
+		case sessions.AgentIdle:
+			// Only when a turn finishes, not on every redraw of the prompt
+			if ev.Previous != string(sessions.AgentWorking) {
+				return
+			}
+			n.Title = "Waiting for input"
+			n.Body = fmt.Sprintf("%s finished and is waiting for you.", name)
+		default:
+			return
+		}
+	default:
+		return
+	}
+	s.push.Notify(n)
+}
+
+// handlePush handles /api/push/... routes.
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	if s.push == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "push notifications unavailable"})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/api/push/vapid-public-key":
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"public_key": s.push.Keys.PublicKey})
+
+	case "/api/push/subscriptions":
+		switch r.Method {
+		case http.MethodGet:
+			list := s.push.Store.List()
+			result := make([]map[string]interface{}, 0, len(list))
+			for _, sub := range list {
+				// Keys are the subscriber's secrets; never echo them back
+				result = append(result, map[string]interface{}{"endpoint": sub.Endpoint, "created_at": sub.CreatedAt})
+			}
+			writeJSON(w, http.StatusOK, result)
+
+		case http.MethodPost:
+			var sub push.Subscription
+			if err := readJSON(r, &sub); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+				return
+			}
+			if err := sub.Validate(); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			sub.CreatedAt = time.Time{}
+			s.push.Store.Add(sub)
+			writeJSON(w, http.StatusCreated, map[string]string{"status": "subscribed"})
+
+		case http.MethodDelete:
+			var req struct {
+				Endpoint string `json:"endpoint"`
+			}
+			if err := readJSON(r, &req); err != nil || req.Endpoint == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "endpoint is required"})
+				return
+			}
+			if !s.push.Store.Remove(req.Endpoint) {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": "subscription not found"})
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]string{"status": "unsubscribed"})
+
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+
+	case "/api/push/test":
+		// POST /api/push/test — deliver a test notification synchronously and report per-endpoint results
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		payload, _ := json.Marshal(push.Notification{Title: "cc-web", Body: "Test notification", URL: "/"})
+		results := map[string]string{}
+		for _, sub := range s.push.Store.List() {
+			ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+			if err := s.push.Send(ctx, sub, payload); err != nil {
+				results[sub.Endpoint] = err.Error()
+			} else {
+				results[sub.Endpoint] = "ok"
+			}
+			cancel()
+		}
+		writeJSON(w, http.StatusOK, results)
+
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
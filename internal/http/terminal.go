@@ -0,0 +1,120 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Built-in terminal bridge (terminal_backend: builtin).
+//
+// The WebSocket at /t/{id}/ws speaks the ttyd protocol so any xterm.js
+// front end written for ttyd works unchanged:
+//   - the first client message is JSON {"columns": N, "rows": M}
+//   - client messages then start with '0' (input) or '1' (resize JSON);
+//     '2'/'3' (pause/resume) are accepted and ignored
+//   - server messages start with '0' followed by terminal output
+
+const (
+	ttyInput  = '0'
+	ttyResize = '1'
+	ttyOutput = '0'
+)
+
+// terminalSize is the resize payload of the ttyd protocol.
+type terminalSize struct {
+	Columns int `json:"columns"`
+	Rows    int `json:"rows"`
+}
+
+// valid reports whether the size is usable for a PTY.
+func (ts terminalSize) valid() bool {
+	return ts.Columns > 0 && ts.Columns <= 1000 && ts.Rows > 0 && ts.Rows <= 1000
+}
+
+// handleBuiltinTerminal serves the terminal page and its WebSocket for a session.
+func (s *Server) handleBuiltinTerminal(w http.ResponseWriter, r *http.Request, sessionID, rest string) {
+	if _, ok := s.mgr.Get(sessionID); !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	switch rest {
+	case "", "index.html":
+		http.ServeFile(w, r, "web/static/terminal.html")
+	case "ws":
+		s.serveTerminalSocket(w, r, sessionID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveTerminalSocket bridges a WebSocket to a tmux client running under a PTY.
+func (s *Server) serveTerminalSocket(w http.ResponseWriter, r *http.Request, sessionID string) {
+	ws, err := upgradeWebSocket(w, r, "tty")
+	if err != nil {
+		log.Printf("terminal %s: %v", sessionID, err)
+		return
+	}
+	defer ws.Close()
+
+	// First message carries the initial terminal size
+	size := terminalSize{Columns: 80, Rows: 24}
+	_, msg, err := ws.ReadMessage()
+	if err != nil {
+		return
+	}
+	var init terminalSize
+	if json.Unmarshal(msg, &init) == nil && init.valid() {
+		size = init
+	}
+
+	term, err := s.mgr.AttachTerminal(sessionID, size.Columns, size.Rows)
+	if err != nil {
+		log.Printf("terminal %s: attach: %v", sessionID, err)
+		return
+	}
+	defer term.Close()
+
+	// Output pump: PTY -> WebSocket. Ends when tmux detaches or the socket dies.
+	go func() {
+		defer ws.Close()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := term.Read(buf)
+			if n > 0 {
+				frame := append([]byte{ttyOutput}, buf[:n]...)
+				if werr := ws.WriteMessage(wsBinary, frame); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Input loop: WebSocket -> PTY
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if len(msg) == 0 {
+			continue
+		}
+		switch msg[0] {
+		case ttyInput:
+			if _, err := term.Write(msg[1:]); err != nil {
+				return
+			}
+		case ttyResize:
+			var ts terminalSize
+			if json.Unmarshal(msg[1:], &ts) == nil && ts.valid() {
+				if err := term.Resize(ts.Columns, ts.Rows); err != nil {
+					log.Printf("terminal %s: resize: %v", sessionID, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/user/cc-web/internal/sessions"
+)
+
+// maxUploadFiles bounds the number of files in one upload request.
+const maxUploadFiles = 20
+
+// handleUpload stores the files of a multipart/form-data request in the
+// session's upload directory. With ?type=1 the saved paths are typed at the
+// prompt (without Enter) once all files are stored. The request succeeds
+// or fails as a whole: files saved before an error are removed again.
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if _, ok := s.mgr.Get(id); !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return
+	}
+	maxBytes := int64(s.cfg.UploadMaxMB) << 20
+	// Room for every file at the limit plus multipart framing
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadFiles*maxBytes+1<<20)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected multipart/form-data"})
+		return
+	}
+	uploads := []*sessions.Upload{}
+	stored := false
+	defer func() {
+		if !stored {
+			discardUploads(uploads)
+		}
+	}()
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+		if len(uploads) == maxUploadFiles {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "too many files"})
+			return
+		}
+		up, err := s.mgr.SaveUpload(id, part.FileName(), part, maxBytes)
+		part.Close()
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		uploads = append(uploads, up)
+	}
+	if len(uploads) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": `no "file" parts in the request`})
+		return
+	}
+
+	if t := r.URL.Query().Get("type"); t == "1" || t == "true" {
+		paths := make([]string, len(uploads))
+		for i, up := range uploads {
+			paths[i] = up.Path
+		}
+		if err := s.mgr.TypePaths(id, paths); err != nil {
+			writeSessionError(w, err)
+			return
+		}
+	}
+	stored = true
+	writeJSON(w, http.StatusCreated, uploads)
+}
+
+// discardUploads removes files saved by a request that then failed.
+func discardUploads(uploads []*sessions.Upload) {
+	for _, up := range uploads {
+		if err := os.Remove(up.Path); err != nil {
+			log.Printf("upload: remove %s: %v", up.Path, err)
+		}
+	}
+}
+
+// writeUploadError reports a failed upload; a body over the request limit
+// is reported like a file over the per-file limit.
+func writeUploadError(w http.ResponseWriter, err error) {
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) {
+		err = sessions.ErrUploadTooLarge
+	}
+	writeSessionError(w, err)
+}
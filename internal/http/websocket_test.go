@@ -0,0 +1,89 @@
+package http
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebSocketEcho(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgradeWebSocket(w, r, "tty")
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		op, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		ws.WriteMessage(op, append([]byte("echo:"), msg...))
+	}))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Key and accept value from RFC 6455 section 1.3
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+strings.TrimPrefix(ts.URL, "http://")+"\r\n"+
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Protocol: chat, tty\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q", got)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "tty" {
+		t.Errorf("Sec-WebSocket-Protocol = %q, want tty", got)
+	}
+
+	// Masked text frame "hi"
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x81, 0x80 | 2}
+	frame = append(frame, mask...)
+	frame = append(frame, 'h'^mask[0], 'i'^mask[1])
+	conn.Write(frame)
+
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		t.Fatal(err)
+	}
+	if hdr[0] != 0x81 {
+		t.Errorf("opcode byte = %#x, want 0x81", hdr[0])
+	}
+	payload := make([]byte, hdr[1])
+	io.ReadFull(br, payload)
+	if string(payload) != "echo:hi" {
+		t.Errorf("payload = %q, want %q", payload, "echo:hi")
+	}
+}
+
+func TestWebSocketRejectsCrossOrigin(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://gateway.local/t/x/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+
+	if _, err := upgradeWebSocket(w, req); err == nil {
+		t.Fatal("expected cross-origin upgrade to fail")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/user/cc-web/internal/sessions"
+)
+
+// handleWindows serves the window and pane routes of a session; rest is
+// the path after /api/sessions/{id}/windows:
+//
+//	GET    /windows                        list windows and panes
+//	POST   /windows                        create a window {name, cwd, cmd, select}
+//	POST   /windows/{w}/select             select a window
+//	DELETE /windows/{w}                    close a window
+//	POST   /windows/{w}/panes              split a pane {cwd, cmd, horizontal, select}
+//	POST   /windows/{w}/panes/{p}/select   select a pane
+//	DELETE /windows/{w}/panes/{p}          close a pane
+func (s *Server) handleWindows(w http.ResponseWriter, r *http.Request, id, rest string) {
+	parts := strings.Split(rest, "/")
+	switch {
+	case rest == "":
+		switch r.Method {
+		case http.MethodGet:
+			windows, err := s.mgr.Windows(id)
+			if err != nil {
+				writeSessionError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, windows)
+		case http.MethodPost:
+			req, ok := s.readWindowRequest(w, r)
+			if !ok {
+				return
+			}
+			win, err := s.mgr.NewWindow(id, req)
+			if err != nil {
+				writeSessionError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, win)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+
+	case len(parts) == 1:
+		s.handleTarget(w, r, id, parts[0], "")
+	case len(parts) == 2 && parts[1] == "select":
+		s.handleTarget(w, r, id, parts[0], "select")
+
+	case len(parts) == 2 && parts[1] == "panes":
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		req, ok := s.readWindowRequest(w, r)
+		if !ok {
+			return
+		}
+		pane, err := s.mgr.SplitPane(id, parts[0], req)
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, pane)
+
+	case len(parts) == 3 && parts[1] == "panes":
+		s.handleTarget(w, r, id, parts[0]+"."+parts[2], "")
+	case len(parts) == 4 && parts[1] == "panes" && parts[3] == "select":
+		s.handleTarget(w, r, id, parts[0]+"."+parts[2], "select")
+
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+// handleTarget selects (POST .../select) or closes (DELETE) a window or pane.
+func (s *Server) handleTarget(w http.ResponseWriter, r *http.Request, id, target, action string) {
+	var err error
+	switch {
+	case action == "select" && r.Method == http.MethodPost:
+		err = s.mgr.SelectTarget(id, target)
+	case action == "" && r.Method == http.MethodDelete:
+		err = s.mgr.CloseTarget(id, target)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if err != nil {
+		writeSessionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "target": target})
+}
+
+// readWindowRequest decodes a window or pane request and checks its cwd
+// against the allowlist.
+func (s *Server) readWindowRequest(w http.ResponseWriter, r *http.Request) (sessions.WindowRequest, bool) {
+	var req sessions.WindowRequest
+	if r.ContentLength != 0 {
+		if err := readJSON(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+			return req, false
+		}
+	}
+	if req.CWD != "" && !s.cfg.IsPathAllowed(req.CWD) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cwd is not in allowed list"})
+		return req, false
+	}
+	return req, true
+}
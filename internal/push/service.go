@@ -0,0 +1,148 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Notification is the JSON payload delivered to the service worker.
+type Notification struct {
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	SessionID string `json:"session_id,omitempty"`
+	URL       string `json:"url,omitempty"`
+	// Tag lets the browser replace an older notification for the same session.
+	Tag string `json:"tag,omitempty"`
+}
+
+// DeliveryError reports a push service response other than 2xx.
+type DeliveryError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("push service returned %d: %s", e.StatusCode, e.Body)
+}
+
+// Gone reports whether the subscription no longer exists and should be dropped.
+func (e *DeliveryError) Gone() bool {
+	return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+}
+
+// notificationTTL is how long the push service keeps an undelivered message.
+const notificationTTL = 24 * time.Hour
+
+// Service sends Web Push notifications to all stored subscriptions.
+// Notify is non-blocking; deliveries happen on a background worker.
+type Service struct {
+	Keys    *VAPIDKeys
+	Store   *Store
+	subject string
+	client  *http.Client
+	queue   chan Notification
+}
+
+// NewService loads (or creates) the VAPID keys and subscription store and
+// starts the delivery worker. subject is the VAPID contact (mailto: or https:).
+func NewService(keysPath, subsPath, subject string) (*Service, error) {
+	keys, err := LoadOrCreateKeys(keysPath)
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewStore(subsPath)
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		Keys:    keys,
+		Store:   store,
+		subject: subject,
+		client:  &http.Client{Timeout: 15 * time.Second},
+		queue:   make(chan Notification, 64),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Notify queues n for delivery to every subscription. If the queue is full
+// the notification is dropped rather than blocking the caller.
+func (s *Service) Notify(n Notification) {
+	select {
+	case s.queue <- n:
+	default:
+		log.Printf("push: queue full, dropping notification %q", n.Title)
+	}
+}
+
+func (s *Service) run() {
+	for n := range s.queue {
+		payload, err := json.Marshal(n)
+		if err != nil {
+			log.Printf("push: marshal notification: %v", err)
+			continue
+		}
+		for _, sub := range s.Store.List() {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			err := s.Send(ctx, sub, payload)
+			cancel()
+			if err != nil {
+				log.Printf("push: deliver to %s: %v", sub.Endpoint, err)
+			}
+		}
+	}
+}
+
+// Send encrypts payload for sub and POSTs it to the subscription endpoint.
+// Subscriptions the push service reports as gone are removed from the store.
+func (s *Service) Send(ctx context.Context, sub Subscription, payload []byte) error {
+	uaPublic, err := b64.DecodeString(sub.Keys.P256dh)
+	if err != nil {
+		return fmt.Errorf("decode p256dh: %w", err)
+	}
+	authSecret, err := b64.DecodeString(sub.Keys.Auth)
+	if err != nil {
+		return fmt.Errorf("decode auth: %w", err)
+	}
+	body, err := encrypt(payload, uaPublic, authSecret)
+	if err != nil {
+		return err
+	}
+	auth, err := s.Keys.authorization(sub.Endpoint, s.subject)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build push request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(notificationTTL.Seconds())))
+	req.Header.Set("Urgency", "high")
+	req.Header.Set("Authorization", auth)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("push request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	derr := &DeliveryError{StatusCode: resp.StatusCode, Body: string(msg)}
+	if derr.Gone() {
+		s.Store.Remove(sub.Endpoint)
+	}
+	return derr
+}
@@ -0,0 +1,189 @@
+package push
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSubscriber plays the browser: it owns the subscription keys and
+// decrypts what the push service receives.
+type testSubscriber struct {
+	priv *ecdh.PrivateKey
+	auth []byte
+}
+
+func newTestSubscriber(t *testing.T) *testSubscriber {
+	t.Helper()
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return &testSubscriber{priv: priv, auth: auth}
+}
+
+func (ts *testSubscriber) subscription(endpoint string) Subscription {
+	return Subscription{
+		Endpoint: endpoint,
+		Keys: SubscriptionKeys{
+			P256dh: b64.EncodeToString(ts.priv.PublicKey().Bytes()),
+			Auth:   b64.EncodeToString(ts.auth),
+		},
+	}
+}
+
+func (ts *testSubscriber) decrypt(t *testing.T, body []byte) []byte {
+	t.Helper()
+	salt := body[:16]
+	if rs := binary.BigEndian.Uint32(body[16:20]); rs != recordSize {
+		t.Fatalf("record size = %d", rs)
+	}
+	idLen := int(body[20])
+	asPublic := body[21 : 21+idLen]
+	ciphertext := body[21+idLen:]
+
+	asKey, err := ecdh.P256().NewPublicKey(asPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, err := ts.priv.ECDH(asKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cek, nonce, err := deriveKeys(shared, ts.auth, ts.priv.PublicKey().Bytes(), asPublic, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if plain[len(plain)-1] != 0x02 {
+		t.Fatalf("missing last-record delimiter")
+	}
+	return plain[:len(plain)-1]
+}
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	dir := t.TempDir()
+	svc, err := NewService(filepath.Join(dir, "vapid.json"), filepath.Join(dir, "subs.json"), "mailto:test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestSendToStandInEndpoint(t *testing.T) {
+	svc := newTestService(t)
+	sub := newTestSubscriber(t)
+
+	var gotBody []byte
+	var gotHeader http.Header
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer endpoint.Close()
+
+	if err := svc.Send(context.Background(), sub.subscription(endpoint.URL+"/push/abc"), []byte(`{"title":"hi"}`)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if got := gotHeader.Get("Content-Encoding"); got != "aes128gcm" {
+		t.Errorf("Content-Encoding = %q", got)
+	}
+	if got := sub.decrypt(t, gotBody); string(got) != `{"title":"hi"}` {
+		t.Errorf("payload = %q", got)
+	}
+
+	// Authorization: vapid t=<jwt>, k=<public key>
+	auth := gotHeader.Get("Authorization")
+	parts := strings.SplitN(strings.TrimPrefix(auth, "vapid t="), ", k=", 2)
+	if len(parts) != 2 || parts[1] != svc.Keys.PublicKey {
+		t.Fatalf("Authorization = %q", auth)
+	}
+	segs := strings.Split(parts[0], ".")
+	if len(segs) != 3 {
+		t.Fatalf("jwt has %d segments", len(segs))
+	}
+	var claims map[string]interface{}
+	claimsJSON, _ := b64.DecodeString(segs[1])
+	json.Unmarshal(claimsJSON, &claims)
+	if claims["aud"] != endpoint.URL || claims["sub"] != "mailto:test@example.com" {
+		t.Errorf("claims = %v", claims)
+	}
+	sig, _ := b64.DecodeString(segs[2])
+	digest := sha256.Sum256([]byte(segs[0] + "." + segs[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(&svc.Keys.priv.PublicKey, digest[:], r, s) {
+		t.Error("jwt signature does not verify")
+	}
+}
+
+func TestSendRemovesGoneSubscription(t *testing.T) {
+	svc := newTestService(t)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer endpoint.Close()
+
+	s := newTestSubscriber(t).subscription(endpoint.URL)
+	svc.Store.Add(s)
+	err := svc.Send(context.Background(), s, []byte("x"))
+	var derr *DeliveryError
+	if !errors.As(err, &derr) || !derr.Gone() {
+		t.Fatalf("Send error = %v, want gone DeliveryError", err)
+	}
+	if n := len(svc.Store.List()); n != 0 {
+		t.Errorf("store has %d subscriptions after 410, want 0", n)
+	}
+}
+
+func TestLoadOrCreateKeysRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vapid.json")
+	k1, err := LoadOrCreateKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := LoadOrCreateKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k1.PublicKey != k2.PublicKey || k1.PrivateKey != k2.PrivateKey {
+		t.Error("reloaded keys differ from generated keys")
+	}
+}
+
+func TestSubscriptionValidate(t *testing.T) {
+	ts := newTestSubscriber(t)
+	for endpoint, ok := range map[string]bool{
+		"https://push.example/abc": true,
+		"http://push.example/abc":  false,
+		"/push/abc":                false,
+	} {
+		sub := ts.subscription(endpoint)
+		if err := sub.Validate(); (err == nil) != ok {
+			t.Errorf("Validate(%q) = %v", endpoint, err)
+		}
+	}
+}
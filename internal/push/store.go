@@ -0,0 +1,112 @@
+package push
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Subscription is a browser PushSubscription as produced by
+// PushSubscription.toJSON(): an endpoint plus the client's encryption keys.
+type Subscription struct {
+	Endpoint  string           `json:"endpoint"`
+	Keys      SubscriptionKeys `json:"keys"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+type SubscriptionKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// Validate checks that the endpoint is an absolute https URL and the keys
+// decode to a P-256 point and a 16-byte auth secret.
+func (s *Subscription) Validate() error {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || u.Host == "" || u.Scheme != "https" {
+		return errors.New("endpoint must be an absolute https URL")
+	}
+	if p, err := b64.DecodeString(s.Keys.P256dh); err != nil || len(p) != 65 {
+		return errors.New("keys.p256dh must be a base64url P-256 public key")
+	}
+	if a, err := b64.DecodeString(s.Keys.Auth); err != nil || len(a) != 16 {
+		return errors.New("keys.auth must be a base64url 16-byte secret")
+	}
+	return nil
+}
+
+// Store keeps push subscriptions, persisted as JSON keyed by endpoint.
+type Store struct {
+	mu   sync.RWMutex
+	path string
+	subs map[string]*Subscription
+}
+
+// NewStore loads subscriptions from path (missing file means none).
+func NewStore(path string) (*Store, error) {
+	st := &Store{path: path, subs: make(map[string]*Subscription)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return nil, fmt.Errorf("read push subscriptions: %w", err)
+	}
+	if err := json.Unmarshal(data, &st.subs); err != nil {
+		return nil, fmt.Errorf("parse push subscriptions: %w", err)
+	}
+	if st.subs == nil {
+		st.subs = make(map[string]*Subscription)
+	}
+	return st, nil
+}
+
+// Add stores or replaces the subscription for its endpoint.
+func (st *Store) Add(sub Subscription) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if sub.CreatedAt.IsZero() {
+		sub.CreatedAt = time.Now()
+	}
+	st.subs[sub.Endpoint] = &sub
+	st.saveLocked()
+}
+
+// Remove deletes the subscription for endpoint and reports whether it existed.
+func (st *Store) Remove(endpoint string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.subs[endpoint]; !ok {
+		return false
+	}
+	delete(st.subs, endpoint)
+	st.saveLocked()
+	return true
+}
+
+// List returns copies of all subscriptions.
+func (st *Store) List() []Subscription {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	result := make([]Subscription, 0, len(st.subs))
+	for _, s := range st.subs {
+		result = append(result, *s)
+	}
+	return result
+}
+
+func (st *Store) saveLocked() {
+	data, err := json.MarshalIndent(st.subs, "", "  ")
+	if err != nil {
+		log.Printf("push: marshal error: %v", err)
+		return
+	}
+	if err := os.WriteFile(st.path, data, 0600); err != nil {
+		log.Printf("push: save error: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package push
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"time"
+)
+
+// b64 is the unpadded base64url encoding used throughout Web Push.
+var b64 = base64.RawURLEncoding
+
+// VAPIDKeys is an application server key pair (RFC 8292), stored as
+// base64url like other Web Push tooling does: the uncompressed P-256 public
+// point and the raw 32-byte private scalar.
+type VAPIDKeys struct {
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+
+	priv *ecdsa.PrivateKey
+}
+
+// LoadOrCreateKeys reads the key pair at path, generating and saving a new
+// one if the file does not exist yet.
+func LoadOrCreateKeys(path string) (*VAPIDKeys, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var k VAPIDKeys
+		if err := json.Unmarshal(data, &k); err != nil {
+			return nil, fmt.Errorf("parse vapid keys: %w", err)
+		}
+		if err := k.parse(); err != nil {
+			return nil, fmt.Errorf("vapid keys %s: %w", path, err)
+		}
+		return &k, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read vapid keys: %w", err)
+	}
+
+	priv, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate vapid keys: %w", err)
+	}
+	k := &VAPIDKeys{
+		PublicKey:  b64.EncodeToString(priv.PublicKey().Bytes()),
+		PrivateKey: b64.EncodeToString(priv.Bytes()),
+	}
+	if err := k.parse(); err != nil {
+		return nil, err
+	}
+	data, _ = json.MarshalIndent(k, "", "  ")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("save vapid keys: %w", err)
+	}
+	return k, nil
+}
+
+// parse builds the ECDSA signing key from the encoded fields.
+func (k *VAPIDKeys) parse() error {
+	raw, err := b64.DecodeString(k.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("decode private key: %w", err)
+	}
+	priv, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return fmt.Errorf("invalid private key: %w", err)
+	}
+	pub := priv.PublicKey().Bytes() // 0x04 || X || Y
+	if b64.EncodeToString(pub) != k.PublicKey {
+		return fmt.Errorf("public key does not match private key")
+	}
+	k.priv = &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}
+	return nil
+}
+
+// authorization returns the VAPID Authorization header value for a push
+// endpoint: a signed ES256 JWT scoped to the endpoint's origin plus our key.
+func (k *VAPIDKeys) authorization(endpoint, subject string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse endpoint: %w", err)
+	}
+	header := b64.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, _ := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": subject,
+	})
+	signingInput := header + "." + b64.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, k.priv, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign vapid jwt: %w", err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	return fmt.Sprintf("vapid t=%s.%s, k=%s", signingInput, b64.EncodeToString(sig), k.PublicKey), nil
+}
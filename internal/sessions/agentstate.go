@@ -0,0 +1,133 @@
+package sessions
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/user/cc-web/internal/config"
+)
+
+// AgentState describes what the program in a session is doing, as inferred
+// from its screen.
+type AgentState string
+
+const (
+	AgentWorking          AgentState = "working"
+	AgentIdle             AgentState = "idle"
+	AgentAwaitingApproval AgentState = "awaiting_approval"
+	AgentErrored          AgentState = "errored"
+	AgentExited           AgentState = "exited"
+	AgentUnknown          AgentState = "unknown"
+)
+
+// agentStateTailLines is how many non-blank lines from the bottom of the
+// screen are matched; older output still on screen should not decide the state.
+const agentStateTailLines = 15
+
+type agentStateRule struct {
+	state   AgentState
+	pattern *regexp.Regexp
+}
+
+// agentDetector classifies a screen using ordered pattern rules.
+type agentDetector struct {
+	rules []agentStateRule
+}
+
+func newAgentDetector(rules []config.AgentStateRule) (*agentDetector, error) {
+	if rules == nil {
+		rules = config.DefaultAgentStateRules
+	}
+	d := &agentDetector{}
+	for i, r := range rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("agent state rule %d: %w", i, err)
+		}
+		d.rules = append(d.rules, agentStateRule{state: AgentState(r.State), pattern: re})
+	}
+	return d, nil
+}
+
+// detect returns the state of the first rule matching the bottom of screen.
+func (d *agentDetector) detect(screen string) AgentState {
+	tail := screenTail(screen, agentStateTailLines)
+	for _, r := range d.rules {
+		if r.pattern.MatchString(tail) {
+			return r.state
+		}
+	}
+	return AgentUnknown
+}
+
+// screenTail returns the last n non-blank lines of screen.
+func screenTail(screen string, n int) string {
+	lines := strings.Split(screen, "\n")
+	var kept []string
+	for i := len(lines) - 1; i >= 0 && len(kept) < n; i-- {
+		if strings.TrimSpace(lines[i]) != "" {
+			kept = append(kept, lines[i])
+		}
+	}
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	return strings.Join(kept, "\n")
+}
+
+// RunAgentStateDetector refreshes AgentState of all running sessions every
+// agent_state_interval until ctx is cancelled.
+func (m *Manager) RunAgentStateDetector(ctx context.Context) {
+	interval := m.cfg.AgentStateInterval
+	if interval <= 0 {
+		interval = 3 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.refreshAgentStates()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshAgentStates captures each running session's screen outside the lock
+// and stores the detected state.
+func (m *Manager) refreshAgentStates() {
+	m.mu.RLock()
+	targets := make(map[string]string, len(m.sessions))
+	for id, s := range m.sessions {
+		if s.Status != StatusExited {
+			targets[id] = s.TmuxName
+		}
+	}
+	m.mu.RUnlock()
+
+	states := make(map[string]AgentState, len(targets))
+	for id, tmuxName := range targets {
+		screen, err := m.mux.CapturePane(tmuxName, false)
+		if err != nil {
+			// Most likely the session just exited; List/Get will notice
+			continue
+		}
+		states[id] = m.agents.detect(screen)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, state := range states {
+		s, ok := m.sessions[id]
+		if !ok || s.Status == StatusExited || s.AgentState == state {
+			continue
+		}
+		prev := s.AgentState
+		s.AgentState = state
+		m.emit(EventAgentState, s, string(prev))
+	}
+}
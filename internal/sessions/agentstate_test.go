@@ -0,0 +1,35 @@
+package sessions
+
+import "testing"
+
+func TestAgentDetectorDefaults(t *testing.T) {
+	d, err := newAgentDetector(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		screen   string
+		expected AgentState
+	}{
+		{"╭──────╮\n│ > \n╰──────╯\n  ? for shortcuts\n", AgentIdle},
+		{"✻ Thinking… (12s · esc to interrupt)\n\n│ > \n", AgentWorking},
+		{" Do you want to make this edit to main.go?\n ❯ 1. Yes\n   2. No\n", AgentAwaitingApproval},
+		{"  ⎿  API Error: 529 overloaded\n\n│ > \n", AgentErrored},
+		{"$ ls\nfoo bar\n", AgentUnknown},
+	}
+
+	for _, tt := range tests {
+		got := d.detect(tt.screen)
+		if got != tt.expected {
+			t.Errorf("detect(%q) = %q, want %q", tt.screen, got, tt.expected)
+		}
+	}
+}
+
+func TestScreenTail(t *testing.T) {
+	got := screenTail("a\n\nb\nc\n\n\n", 2)
+	if got != "b\nc" {
+		t.Errorf("screenTail = %q, want %q", got, "b\nc")
+	}
+}
@@ -0,0 +1,242 @@
+package sessions
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// controlSessionName is the tmux session the control-mode client attaches
+// to. It holds no work; it only gives the client something to attach to and
+// keeps the tmux server alive while the gateway runs.
+const controlSessionName = "cc-web-control"
+
+// errControlClosed is returned for commands issued after the control-mode
+// connection went away. TmuxRunner falls back to exec when it sees it.
+var errControlClosed = errors.New("tmux control mode: connection closed")
+
+// controlNotification is an asynchronous line from tmux outside a command
+// response, e.g. "%sessions-changed" or "%output %1 data".
+type controlNotification struct {
+	Name string // without the leading '%'
+	Args string
+	Pane string // %output only
+	Data []byte // %output only, unescaped
+}
+
+type controlResult struct {
+	lines  []string
+	failed bool // %error instead of %end
+}
+
+// controlClient is one long-lived `tmux -C` connection. Commands are written
+// to stdin one per line; tmux answers each in order with a %begin ... %end
+// (or %error) block, so responses are matched to a FIFO of waiters.
+type controlClient struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	mu      sync.Mutex // serializes writes and guards pending
+	pending []chan controlResult
+	closed  bool
+
+	done     chan struct{}
+	onNotify func(controlNotification)
+}
+
+// startControlClient starts `tmux -C` attached to the control session,
+// creating it if needed. server holds the -L/-S/-f flags selecting the tmux
+// server. onNotify runs on the reader goroutine and must not issue commands
+// on the same client.
+func startControlClient(server []string, onNotify func(controlNotification)) (*controlClient, error) {
+	args := append(append([]string(nil), server...), "-C", "new-session", "-A", "-s", controlSessionName)
+	cmd := exec.Command("tmux", args...)
+	cmd.Env = terminalEnv()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("tmux -C: %w", err)
+	}
+
+	c := &controlClient{
+		cmd:      cmd,
+		stdin:    stdin,
+		done:     make(chan struct{}),
+		onNotify: onNotify,
+	}
+	go c.read(stdout)
+	return c, nil
+}
+
+// run sends one command and waits for its response. On %error the
+// response lines (tmux's message) are returned together with failed set.
+func (c *controlClient) run(args []string) (controlResult, error) {
+	ch := make(chan controlResult, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return controlResult{}, errControlClosed
+	}
+	c.pending = append(c.pending, ch)
+	if _, err := io.WriteString(c.stdin, controlCommand(args)+"\n"); err != nil {
+		c.pending = c.pending[:len(c.pending)-1]
+		c.mu.Unlock()
+		return controlResult{}, errControlClosed
+	}
+	c.mu.Unlock()
+
+	select {
+	case res := <-ch:
+		return res, nil
+	case <-c.done:
+		select {
+		case res := <-ch:
+			return res, nil
+		default:
+			return controlResult{}, errControlClosed
+		}
+	}
+}
+
+// read parses tmux output until the client exits.
+func (c *controlClient) read(r io.Reader) {
+	defer c.shutdown()
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	var (
+		inBlock bool
+		ours    bool   // block answers a command we sent (flags = 1)
+		guard   string // "<time> <number> <flags>" that the closing line must repeat
+		lines   []string
+	)
+	for sc.Scan() {
+		line := sc.Text()
+		if inBlock {
+			if rest, ok := strings.CutPrefix(line, "%end "); ok && rest == guard {
+				c.finish(ours, controlResult{lines: lines})
+				inBlock, lines = false, nil
+				continue
+			}
+			if rest, ok := strings.CutPrefix(line, "%error "); ok && rest == guard {
+				c.finish(ours, controlResult{lines: lines, failed: true})
+				inBlock, lines = false, nil
+				continue
+			}
+			lines = append(lines, line)
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(line, "%begin "); ok {
+			inBlock, guard = true, rest
+			fields := strings.Fields(rest)
+			ours = len(fields) == 3 && fields[2] == "1"
+			continue
+		}
+		if !strings.HasPrefix(line, "%") || c.onNotify == nil {
+			continue
+		}
+		name, args, _ := strings.Cut(line[1:], " ")
+		n := controlNotification{Name: name, Args: args}
+		if name == "output" {
+			pane, data, _ := strings.Cut(args, " ")
+			n.Pane, n.Data = pane, unescapeControlOutput(data)
+		}
+		c.onNotify(n)
+	}
+}
+
+// finish hands a response to the oldest waiter. Blocks not started by
+// this client (the initial attach, other clients' hooks) have no waiter.
+func (c *controlClient) finish(ours bool, res controlResult) {
+	if !ours {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 {
+		return
+	}
+	ch := c.pending[0]
+	c.pending = c.pending[1:]
+	ch <- res
+}
+
+func (c *controlClient) shutdown() {
+	c.mu.Lock()
+	c.closed = true
+	c.pending = nil
+	c.mu.Unlock()
+	_ = c.cmd.Wait()
+	close(c.done)
+}
+
+// Done is closed when the control-mode connection has ended.
+func (c *controlClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close kills the control session, which detaches the client, and waits
+// for it to exit. tmux exits with it if no other sessions are left.
+func (c *controlClient) Close() {
+	_, _ = c.run([]string{"kill-session", "-t", controlSessionName})
+	c.mu.Lock()
+	c.stdin.Close()
+	c.mu.Unlock()
+	<-c.done
+}
+
+// controlCommand renders args as one tmux command line. Every argument is
+// double-quoted: tmux expands $VAR and ~ inside double quotes, so those are
+// escaped, and control characters (including newlines, which would end the
+// command) are written as \uXXXX.
+func controlCommand(args []string) string {
+	var b strings.Builder
+	for i, a := range args {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteByte('"')
+		for _, r := range a {
+			switch {
+			case r == '\\' || r == '"' || r == '$' || r == '~':
+				b.WriteByte('\\')
+				b.WriteRune(r)
+			case r < 0x20 || r == 0x7f:
+				fmt.Fprintf(&b, `\u%04x`, r)
+			default:
+				b.WriteRune(r)
+			}
+		}
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// unescapeControlOutput decodes %output data, where tmux writes bytes
+// below 0x20 and backslash as three-digit octal escapes.
+func unescapeControlOutput(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				out = append(out, byte(v))
+				i += 3
+				continue
+			}
+		}
+		out = append(out, s[i])
+	}
+	return out
+}
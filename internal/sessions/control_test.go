@@ -0,0 +1,94 @@
+package sessions
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlCommand(t *testing.T) {
+	got := controlCommand([]string{"send-keys", "-l", `a "$HOME" ~\` + "\n\x1b"})
+	want := `"send-keys" "-l" "a \"\$HOME\" \~\\\u000a\u001b"`
+	if got != want {
+		t.Errorf("controlCommand = %s, want %s", got, want)
+	}
+}
+
+func TestUnescapeControlOutput(t *testing.T) {
+	got := string(unescapeControlOutput(`hi\015\012\134x\9`))
+	if got != "hi\r\n\\x\\9" {
+		t.Errorf("unescapeControlOutput = %q", got)
+	}
+}
+
+func TestTmuxRunner_ControlMode(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	// Private server, so the test never touches the user's sessions
+	socket := filepath.Join(t.TempDir(), "tmux.sock")
+	t.Setenv("TMUX", socket+",0,0")
+
+	changed := make(chan struct{}, 16)
+	runner := NewTmuxRunner()
+	done, err := runner.StartControl(func(n controlNotification) {
+		if n.Name == "sessions-changed" {
+			changed <- struct{}{}
+		}
+	})
+	if err != nil {
+		t.Fatalf("StartControl: %v", err)
+	}
+	defer func() {
+		runner.StopControl()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("control client did not exit")
+		}
+	}()
+
+	if err := runner.CreateSession("cc-test", t.TempDir(), "cat", nil); err != nil {
+		t.Fatal(err)
+	}
+	names, err := runner.ListSessions()
+	if err != nil || len(names) != 1 || names[0] != "cc-test" {
+		t.Fatalf("ListSessions = %v, %v", names, err)
+	}
+
+	text := `echo "$HOME" ~ 'q'`
+	if err := runner.SendKeys("cc-test", text); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		screen, err := runner.CapturePane("cc-test", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(screen, text) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("text not echoed literally, screen:\n%s", screen)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := runner.KillSession("no-such-session"); err == nil {
+		t.Error("expected error killing a missing session")
+	}
+	if err := runner.KillSession("cc-test"); err != nil {
+		t.Fatal(err)
+	}
+	if runner.HasSession("cc-test") {
+		t.Error("session still exists after kill")
+	}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Error("no sessions-changed notification")
+	}
+}
@@ -0,0 +1,168 @@
+package sessions
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies a session lifecycle or state change.
+type EventType string
+
+const (
+	// EventCreated fires after a new session has been started.
+	EventCreated EventType = "created"
+	// EventKilled fires when a session is killed through the API.
+	EventKilled EventType = "killed"
+	// EventRecovered fires on startup for each running session found in tmux.
+	EventRecovered EventType = "recovered"
+	// EventExited fires when a running session's tmux session disappears.
+	EventExited EventType = "exited"
+	// EventStatusChanged fires whenever Session.Status changes; Previous
+	// holds the old status.
+	EventStatusChanged EventType = "status_changed"
+	// EventInputSent fires after text, keys or an interrupt were delivered
+	// to a session; Detail is "text", "paste", "keys" or "interrupt".
+	EventInputSent EventType = "input_sent"
+	// EventTtydRestarted fires when a session's ttyd process is started again.
+	EventTtydRestarted EventType = "ttyd_restarted"
+	// EventRestarted fires when an exited session is started again, or the
+	// dead panes of a running one are respawned; Previous holds the old status.
+	EventRestarted EventType = "restarted"
+	// EventUpdated fires when a session's name, notes, color, emoji or
+	// pinned flag is changed.
+	EventUpdated EventType = "updated"
+	// EventAgentState fires when the detected AgentState of a session changes.
+	EventAgentState EventType = "agent_state"
+	// EventTimeoutWarning fires one timeout_grace before the reaper
+	// interrupts a session; Detail is ReapIdle or ReapMaxLifetime.
+	EventTimeoutWarning EventType = "timeout_warning"
+	// EventTimedOut fires when the reaper kills a session, just before
+	// EventKilled; Detail is ReapIdle or ReapMaxLifetime.
+	EventTimedOut EventType = "timed_out"
+)
+
+// EventTypes lists every event type, for validating filters.
+var EventTypes = []EventType{
+	EventCreated, EventKilled, EventRecovered, EventExited,
+	EventStatusChanged, EventInputSent, EventTtydRestarted, EventRestarted,
+	EventUpdated, EventAgentState, EventTimeoutWarning, EventTimedOut,
+}
+
+// eventLogSize is how many recent events are kept for EventsSince.
+const eventLogSize = 1000
+
+// Event describes a change to a session. Session is a snapshot taken when
+// the event was emitted; Previous holds the prior status or agent state.
+// Seq increases by one per event for the lifetime of the Manager.
+type Event struct {
+	Seq       uint64    `json:"seq"`
+	Type      EventType `json:"type"`
+	SessionID string    `json:"session_id"`
+	Session   Session   `json:"session"`
+	Previous  string    `json:"previous,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// eventLog is the manager's event bus: a bounded list of recent events that
+// readers poll with a wake-up channel, like OutputBuffer does for output.
+type eventLog struct {
+	mu      sync.Mutex
+	events  []Event
+	lastSeq uint64
+	notify  chan struct{}
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{notify: make(chan struct{})}
+}
+
+// Subscribe registers fn to be called for every session event.
+// fn runs synchronously on the goroutine that changed the session, usually
+// with the manager lock held: it must return quickly and must not call back
+// into the Manager. Hand events off to a channel for anything slow.
+func (m *Manager) Subscribe(fn func(Event)) {
+	m.listenersMu.Lock()
+	defer m.listenersMu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// LastEventSeq returns the sequence number of the newest event (0 if none yet).
+func (m *Manager) LastEventSeq() uint64 {
+	m.events.mu.Lock()
+	defer m.events.mu.Unlock()
+	return m.events.lastSeq
+}
+
+// EventsSince returns the retained events with Seq > after. complete is false
+// when events following after have already been dropped from the log, i.e.
+// the reader missed changes and should re-fetch the session list. wait is
+// closed when the next event is published.
+func (m *Manager) EventsSince(after uint64) (events []Event, complete bool, wait <-chan struct{}) {
+	l := m.events
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	complete = true
+	if after > l.lastSeq {
+		// Sequence from a previous gateway process
+		after = l.lastSeq
+		complete = false
+	}
+	if len(l.events) > 0 && after+1 < l.events[0].Seq {
+		complete = false
+	}
+	for _, ev := range l.events {
+		if ev.Seq > after {
+			events = append(events, ev)
+		}
+	}
+	return events, complete, l.notify
+}
+
+// emit delivers an event about s to all subscribers.
+func (m *Manager) emit(typ EventType, s *Session, previous string) {
+	m.publish(Event{Type: typ, SessionID: s.ID, Session: *s, Previous: previous})
+}
+
+// emitDetail is emit with an Event.Detail instead of a previous state.
+func (m *Manager) emitDetail(typ EventType, s *Session, detail string) {
+	m.publish(Event{Type: typ, SessionID: s.ID, Session: *s, Detail: detail})
+}
+
+// emitInput records that input of the given kind was delivered to s,
+// which also counts as activity for idle_timeout. Unlike emit, it is
+// called without m.mu held and takes the snapshot itself.
+func (m *Manager) emitInput(s *Session, kind string) {
+	m.mu.Lock()
+	m.touchLocked(s, time.Now())
+	snap := *s
+	m.mu.Unlock()
+	m.publish(Event{Type: EventInputSent, SessionID: snap.ID, Session: snap, Detail: kind})
+}
+
+// publish numbers ev, appends it to the event log and calls the listeners.
+func (m *Manager) publish(ev Event) {
+	ev.Time = time.Now()
+	// Listeners and the event log must not see the env values
+	ev.Session.Env = nil
+
+	l := m.events
+	l.mu.Lock()
+	l.lastSeq++
+	ev.Seq = l.lastSeq
+	l.events = append(l.events, ev)
+	if len(l.events) > eventLogSize {
+		l.events = l.events[len(l.events)-eventLogSize:]
+	}
+	close(l.notify)
+	l.notify = make(chan struct{})
+	l.mu.Unlock()
+
+	m.listenersMu.RLock()
+	listeners := m.listeners
+	m.listenersMu.RUnlock()
+	for _, fn := range listeners {
+		fn(ev)
+	}
+}
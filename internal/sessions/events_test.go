@@ -0,0 +1,63 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/user/cc-web/internal/config"
+)
+
+func TestEventsSince(t *testing.T) {
+	m := NewManager(&config.Config{})
+	var got []Event
+	m.Subscribe(func(ev Event) { got = append(got, ev) })
+
+	s := &Session{ID: "s1", Env: map[string]string{"API_KEY": "secret"}}
+	m.emit(EventCreated, s, "")
+	m.emit(EventStatusChanged, s, string(StatusRunning))
+
+	if len(got) != 2 || got[0].Seq != 1 || got[1].Seq != 2 {
+		t.Fatalf("listener got %+v", got)
+	}
+	if got[0].Session.Env != nil {
+		t.Errorf("event includes the env: %v", got[0].Session.Env)
+	}
+	if m.LastEventSeq() != 2 {
+		t.Errorf("LastEventSeq = %d, want 2", m.LastEventSeq())
+	}
+
+	events, complete, wait := m.EventsSince(1)
+	if !complete || len(events) != 1 || events[0].Type != EventStatusChanged || events[0].Previous != "running" {
+		t.Errorf("EventsSince(1) = %+v, complete=%v", events, complete)
+	}
+	select {
+	case <-wait:
+		t.Fatal("wait closed before a new event")
+	default:
+	}
+	m.emit(EventKilled, s, "")
+	select {
+	case <-wait:
+	default:
+		t.Fatal("wait not closed by new event")
+	}
+
+	// A sequence from a previous gateway process is reported as incomplete
+	if _, complete, _ := m.EventsSince(100); complete {
+		t.Error("EventsSince(100) should be incomplete")
+	}
+}
+
+func TestEventsSince_Evicted(t *testing.T) {
+	m := NewManager(&config.Config{})
+	s := &Session{ID: "s1"}
+	for i := 0; i < eventLogSize+10; i++ {
+		m.emit(EventAgentState, s, "")
+	}
+	events, complete, _ := m.EventsSince(5)
+	if complete {
+		t.Error("expected incomplete after eviction")
+	}
+	if len(events) != eventLogSize {
+		t.Errorf("got %d events, want %d", len(events), eventLogSize)
+	}
+}
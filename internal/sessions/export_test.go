@@ -0,0 +1,35 @@
+package sessions
+
+import "time"
+
+// Hooks into unexported Manager methods for the sessions_test package,
+// whose tests use sessionstest and so cannot live in package sessions.
+
+// AddSession registers s as if it had been created or recovered.
+func (m *Manager) AddSession(s *Session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.ID] = s
+}
+
+func (m *Manager) RefreshStatuses() { m.refreshStatuses() }
+
+func (m *Manager) StatusSnapshot() map[string]Status { return m.statusSnapshot() }
+
+func (m *Manager) LiveSessions() (alive map[string]bool, exited map[string]*int, err error) {
+	return m.liveSessions()
+}
+
+func (m *Manager) ApplyStatuses(before map[string]Status, alive map[string]bool, exited map[string]*int) {
+	m.applyStatuses(before, alive, exited)
+}
+
+// MarkExited marks a session exited as if it had vanished from the
+// multiplexer.
+func (m *Manager) MarkExited(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.markExitedLocked(m.sessions[id])
+}
+
+func (m *Manager) Reap(now time.Time) { m.reap(now) }
@@ -0,0 +1,79 @@
+package sessions_test
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/user/cc-web/internal/config"
+	"github.com/user/cc-web/internal/sessions"
+	"github.com/user/cc-web/internal/sessions/sessionstest"
+)
+
+func TestHistory(t *testing.T) {
+	fake := sessionstest.NewFakeMultiplexer()
+	m := sessions.NewManagerWithMultiplexer(&config.Config{}, fake)
+	if err := fake.CreateSession("hist", "/", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	m.AddSession(&sessions.Session{ID: "hist", TmuxName: "hist", Status: sessions.StatusRunning})
+	// 20 lines of scrollback above a 10-line pane
+	var screen []string
+	for i := 0; i < 30; i++ {
+		screen = append(screen, fmt.Sprintf("line %d", i))
+	}
+	fake.SetScreen("hist", strings.Join(screen, "\n"))
+	if err := fake.ResizeWindow("hist", 80, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		q        sessions.HistoryQuery
+		wantFrom int
+		want     []int // line numbers; the text of line N is "line N"
+	}{
+		{"tail", sessions.HistoryQuery{From: -1, Lines: 5}, 25, []int{25, 26, 27, 28, 29}},
+		{"tail longer than history", sessions.HistoryQuery{From: -1, Lines: 100}, 0, seq(0, 29)},
+		{"start of scrollback", sessions.HistoryQuery{From: 0, Lines: 3}, 0, []int{0, 1, 2}},
+		{"across the pane top", sessions.HistoryQuery{From: 18, Lines: 4}, 18, []int{18, 19, 20, 21}},
+		{"window clamped at the end", sessions.HistoryQuery{From: 28, Lines: 5}, 28, []int{28, 29}},
+		{"from the end", sessions.HistoryQuery{From: 30, Lines: 5}, 30, nil},
+		{"past the end", sessions.HistoryQuery{From: 40, Lines: 5}, 40, nil},
+		{"search", sessions.HistoryQuery{From: 0, Lines: 100, Pattern: regexp.MustCompile(`line 1`)}, 0, append([]int{1}, seq(10, 19)...)},
+		{"search limited", sessions.HistoryQuery{From: 0, Lines: 2, Pattern: regexp.MustCompile(`line 1`)}, 0, []int{1, 10}},
+		{"search from offset", sessions.HistoryQuery{From: 21, Lines: 3, Pattern: regexp.MustCompile(`line 2`)}, 21, []int{21, 22, 23}},
+		{"search tail", sessions.HistoryQuery{From: -1, Lines: 3, Pattern: regexp.MustCompile(`9$`)}, 27, []int{29}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := m.History("hist", tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Total != 30 || h.From != tt.wantFrom {
+				t.Errorf("total, from = %d, %d; want 30, %d", h.Total, h.From, tt.wantFrom)
+			}
+			var got []int
+			for _, l := range h.Lines {
+				if l.Text != fmt.Sprintf("line %d", l.N) {
+					t.Errorf("line %d = %q", l.N, l.Text)
+				}
+				got = append(got, l.N)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("lines = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// seq returns the integers from first to last inclusive.
+func seq(first, last int) []int {
+	var s []int
+	for i := first; i <= last; i++ {
+		s = append(s, i)
+	}
+	return s
+}
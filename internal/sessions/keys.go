@@ -0,0 +1,216 @@
+package sessions
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidKey is returned for a key token outside the key grammar.
+var ErrInvalidKey = errors.New("invalid key")
+
+const (
+	// maxKeyRepeat bounds the repeat count of one token ("DOWN*5").
+	maxKeyRepeat = 100
+	// maxKeys bounds the number of keys one request expands to.
+	maxKeys = 1000
+)
+
+// namedKeys maps key names, upper-cased with underscores removed, to tmux
+// key names.
+var namedKeys = map[string]string{
+	"ESC": "Escape", "ESCAPE": "Escape",
+	"ENTER": "Enter", "RETURN": "Enter",
+	"TAB": "Tab", "BTAB": "BTab",
+	"SPACE":     "Space",
+	"BACKSPACE": "BSpace", "BSPACE": "BSpace",
+	"UP": "Up", "DOWN": "Down", "LEFT": "Left", "RIGHT": "Right",
+	"HOME": "Home", "END": "End",
+	"PAGEUP": "PPage", "PGUP": "PPage", "PPAGE": "PPage",
+	"PAGEDOWN": "NPage", "PGDN": "NPage", "PGDOWN": "NPage", "NPAGE": "NPage",
+	"DELETE": "DC", "DEL": "DC", "DC": "DC",
+	"INSERT": "IC", "INS": "IC", "IC": "IC",
+	"F1": "F1", "F2": "F2", "F3": "F3", "F4": "F4", "F5": "F5", "F6": "F6",
+	"F7": "F7", "F8": "F8", "F9": "F9", "F10": "F10", "F11": "F11", "F12": "F12",
+}
+
+// modifierKeys maps modifier names to the tmux key prefix they stand for.
+var modifierKeys = map[string]string{
+	"CTRL": "C-", "CONTROL": "C-", "C": "C-",
+	"ALT": "M-", "META": "M-", "OPT": "M-", "OPTION": "M-", "M": "M-",
+	"SHIFT": "S-", "S": "S-",
+}
+
+// ParseKeys turns key tokens into tmux key names for SendRawKeys. A token is
+//
+//	a single character          "y", "1", "/"
+//	a named key                 ESC, ENTER, TAB, SPACE, BACKSPACE, UP, DOWN,
+//	                            LEFT, RIGHT, HOME, END, PAGEUP, PAGEDOWN,
+//	                            DELETE, INSERT, F1-F12
+//	modifiers and a key         CTRL+C, CTRL+LEFT, ALT+F, ALT+ENTER,
+//	                            SHIFT+TAB, SHIFT+UP, CTRL+ALT+X
+//	raw bytes in hex            0x1b5b41
+//
+// optionally followed by a repeat count: DOWN*3. Names are case-insensitive
+// and "_" or "-" also separate modifiers (CTRL_C, C-c). Any other token is
+// rejected with ErrInvalidKey rather than typed as text.
+func ParseKeys(tokens []string) ([]string, error) {
+	var keys []string
+	for _, tok := range tokens {
+		k, err := parseKey(tok)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys)+len(k) > maxKeys {
+			return nil, fmt.Errorf("%w: more than %d keys", ErrInvalidKey, maxKeys)
+		}
+		keys = append(keys, k...)
+	}
+	return keys, nil
+}
+
+// parseKey parses one token, repeat count included.
+func parseKey(tok string) ([]string, error) {
+	count := 1
+	if i := strings.LastIndex(tok, "*"); i > 0 {
+		n, err := strconv.Atoi(tok[i+1:])
+		if err != nil || n < 1 || n > maxKeyRepeat {
+			return nil, fmt.Errorf("%w %q: repeat count must be 1-%d", ErrInvalidKey, tok, maxKeyRepeat)
+		}
+		count, tok = n, tok[:i]
+	}
+
+	var keys []string
+	if digits, ok := cutHexPrefix(tok); ok {
+		b, err := hex.DecodeString(digits)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("%w %q: bad hex bytes", ErrInvalidKey, tok)
+		}
+		for _, c := range b {
+			keys = append(keys, hexKey(c))
+		}
+	} else {
+		k, err := modifiedKey(tok)
+		if err != nil {
+			return nil, err
+		}
+		keys = []string{k}
+	}
+
+	out := make([]string, 0, len(keys)*count)
+	for range count {
+		out = append(out, keys...)
+	}
+	return out, nil
+}
+
+// cutHexPrefix returns the digits of a "0x..." token; a lone "0" is a key.
+func cutHexPrefix(tok string) (string, bool) {
+	if len(tok) > 2 && (strings.HasPrefix(tok, "0x") || strings.HasPrefix(tok, "0X")) {
+		return tok[2:], true
+	}
+	return "", false
+}
+
+// hexKey returns the tmux key for one raw byte; TmuxRunner.SendRawKeys
+// sends runs of these with send-keys -H.
+func hexKey(c byte) string {
+	return fmt.Sprintf("0x%02x", c)
+}
+
+// isHexKey reports whether key is a raw byte produced by hexKey.
+func isHexKey(key string) bool {
+	return len(key) == 4 && strings.HasPrefix(key, "0x")
+}
+
+// modifiedKey parses a key with optional modifiers into a tmux key name.
+func modifiedKey(tok string) (string, error) {
+	var ctrl, alt, shift bool
+	rest := tok
+	for {
+		mod, r, ok := cutModifier(rest)
+		if !ok {
+			break
+		}
+		switch mod {
+		case "C-":
+			ctrl = true
+		case "M-":
+			alt = true
+		case "S-":
+			shift = true
+		}
+		rest = r
+	}
+
+	key, ok := baseKey(rest)
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrInvalidKey, tok)
+	}
+	char := utf8.RuneCountInString(key) == 1
+	if shift {
+		switch {
+		case key == "Tab":
+			key = "BTab"
+		case char && unicode.IsLetter([]rune(key)[0]):
+			key = strings.ToUpper(key)
+		case !char && key != "Escape" && key != "Enter" && key != "Space" && key != "BSpace" && key != "BTab":
+			key = "S-" + key
+		default:
+			return "", fmt.Errorf("%w %q: shift does not apply to %s", ErrInvalidKey, tok, rest)
+		}
+	}
+	if ctrl {
+		switch {
+		case char && key[0] >= 'A' && key[0] <= 'Z':
+			key = "C-" + strings.ToLower(key)
+		case char && (key[0] >= 'a' && key[0] <= 'z' || strings.ContainsRune(`@[\]^_`, rune(key[0]))):
+			key = "C-" + key
+		case !char && key != "Escape" && key != "Enter" && key != "Tab" && key != "BTab" && key != "BSpace":
+			key = "C-" + key
+		default:
+			return "", fmt.Errorf("%w %q: ctrl does not apply to %s", ErrInvalidKey, tok, rest)
+		}
+	}
+	if alt {
+		key = "M-" + key
+	}
+	return key, nil
+}
+
+// cutModifier splits "CTRL+rest" (or CTRL_rest, C-rest) into the modifier's
+// tmux prefix and the rest.
+func cutModifier(tok string) (string, string, bool) {
+	i := strings.IndexAny(tok, "+_-")
+	if i <= 0 || i == len(tok)-1 {
+		return "", "", false
+	}
+	mod, ok := modifierKeys[strings.ToUpper(tok[:i])]
+	if !ok {
+		return "", "", false
+	}
+	return mod, tok[i+1:], true
+}
+
+// baseKey returns the tmux name of a named key or single character.
+func baseKey(tok string) (string, bool) {
+	if utf8.RuneCountInString(tok) == 1 {
+		r, _ := utf8.DecodeRuneInString(tok)
+		switch {
+		case r == utf8.RuneError || unicode.IsControl(r):
+			return "", false
+		case r == ' ':
+			return "Space", true
+		case r == ';':
+			// A lone ";" separates tmux commands
+			return hexKey(';'), true
+		}
+		return tok, true
+	}
+	name, ok := namedKeys[strings.ToUpper(strings.ReplaceAll(tok, "_", ""))]
+	return name, ok
+}
@@ -0,0 +1,71 @@
+package sessions
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"ESC", []string{"Escape"}},
+		{"esc", []string{"Escape"}},
+		{"UP", []string{"Up"}},
+		{"DOWN", []string{"Down"}},
+		{"TAB", []string{"Tab"}},
+		{"ENTER", []string{"Enter"}},
+		{"CTRL_C", []string{"C-c"}},
+		{"CTRL+C", []string{"C-c"}},
+		{"CTRL_D", []string{"C-d"}},
+		{"BACKSPACE", []string{"BSpace"}},
+		{"SPACE", []string{"Space"}},
+		{"a", []string{"a"}},
+		{"F1", []string{"F1"}},
+		{"f12", []string{"F12"}},
+		{"PAGE_UP", []string{"PPage"}},
+		{"PageDown", []string{"NPage"}},
+		{"HOME", []string{"Home"}},
+		{"END", []string{"End"}},
+		{"DELETE", []string{"DC"}},
+		{"INSERT", []string{"IC"}},
+		{"CTRL+R", []string{"C-r"}},
+		{"C-x", []string{"C-x"}},
+		{"CTRL+LEFT", []string{"C-Left"}},
+		{"ALT+F", []string{"M-F"}},
+		{"alt+b", []string{"M-b"}},
+		{"ALT+ENTER", []string{"M-Enter"}},
+		{"CTRL+ALT+X", []string{"M-C-x"}},
+		{"SHIFT+TAB", []string{"BTab"}},
+		{"SHIFT+UP", []string{"S-Up"}},
+		{"DOWN*3", []string{"Down", "Down", "Down"}},
+		{"0x1b5b41", []string{"0x1b", "0x5b", "0x41"}},
+		{"0x03*2", []string{"0x03", "0x03"}},
+		{";", []string{"0x3b"}},
+		{"*", []string{"*"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseKeys([]string{tt.input})
+		if err != nil || !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ParseKeys(%q) = %q, %v; want %q", tt.input, got, err, tt.expected)
+		}
+	}
+}
+
+func TestParseKeys_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"hello", "CTRL+", "CTRL+ENTER", "CTRL+1", "SHIFT+ENTER", "F13",
+		"HYPER+X", "DOWN*0", "DOWN*101", "DOWN*x", "0x1", "0xzz", "\x07", "",
+	} {
+		if got, err := ParseKeys([]string{input}); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("ParseKeys(%q) = %q, %v; want ErrInvalidKey", input, got, err)
+		}
+	}
+	if _, err := ParseKeys([]string{strings.Repeat("x", 1), "DOWN*100", "UP*100", "LEFT*100", "RIGHT*100",
+		"TAB*100", "ESC*100", "HOME*100", "END*100", "F1*100", "F2*100"}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("ParseKeys over %d keys: err = %v, want ErrInvalidKey", maxKeys, err)
+	}
+}
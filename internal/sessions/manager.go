@@ -8,7 +8,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +24,9 @@ var safeIDPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
 // ErrNotFound is returned when a session ID does not exist.
 var ErrNotFound = errors.New("session not found")
 
+// ErrSessionExited is returned for operations that need a running session.
+var ErrSessionExited = errors.New("session has exited")
+
 // IsNotFound reports whether the error indicates a missing session.
 func IsNotFound(err error) bool {
 	return errors.Is(err, ErrNotFound)
@@ -45,16 +50,50 @@ type Manager struct {
 	mu       sync.RWMutex
 	sessions map[string]*Session
 	cfg      *config.Config
-	tmux     *TmuxRunner
+	mux      Multiplexer
 	ttyd     *TtydManager
+	taps     map[string]*outputTap // session ID -> pane output tap
+	pipeDir  string                // FIFOs for output taps, created on first use
+	recs     map[string]*recorder  // session ID -> current asciicast recorder
+	reaps    map[string]*reapState // session ID -> idle/lifetime reaper state
+	agents   *agentDetector
+	events   *eventLog
+	refresh  chan struct{} // asks RunMonitor for an early pass
+
+	listenersMu sync.RWMutex
+	listeners   []func(Event)
 }
 
 func NewManager(cfg *config.Config) *Manager {
+	mux, err := newMultiplexer(cfg)
+	if err != nil {
+		// config.Load validates the backend; only hand-built configs get here
+		log.Printf("sessions: %v, using tmux", err)
+		mux = NewTmuxRunner()
+	}
+	return NewManagerWithMultiplexer(cfg, mux)
+}
+
+// NewManagerWithMultiplexer returns a Manager hosting sessions in mux,
+// e.g. a sessionstest.FakeMultiplexer in tests.
+func NewManagerWithMultiplexer(cfg *config.Config, mux Multiplexer) *Manager {
+	agents, err := newAgentDetector(cfg.AgentStateRules)
+	if err != nil {
+		// config.Load validates the rules; only hand-built configs get here
+		log.Printf("sessions: invalid agent state rules, using defaults: %v", err)
+		agents, _ = newAgentDetector(nil)
+	}
 	return &Manager{
 		sessions: make(map[string]*Session),
 		cfg:      cfg,
-		tmux:     NewTmuxRunner(),
+		mux:      mux,
 		ttyd:     NewTtydManager(cfg),
+		taps:     make(map[string]*outputTap),
+		recs:     make(map[string]*recorder),
+		reaps:    make(map[string]*reapState),
+		agents:   agents,
+		events:   newEventLog(),
+		refresh:  make(chan struct{}, 1),
 	}
 }
 
@@ -64,39 +103,48 @@ func (m *Manager) Recover() error {
 	m.loadFromFile()
 
 	// Cross-check with tmux
-	tmuxSessions, err := m.tmux.ListSessions()
+	tmuxSet, exited, err := m.liveSessions()
 	if err != nil {
 		return fmt.Errorf("recover: %w", err)
 	}
 
-	tmuxSet := make(map[string]bool)
-	for _, name := range tmuxSessions {
-		tmuxSet[name] = true
-	}
-
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// Mark sessions not found in tmux as exited
 	for id, s := range m.sessions {
-		if !tmuxSet[s.TmuxName] {
-			m.sessions[id].Status = StatusExited
+		if code, ok := exited[s.TmuxName]; ok {
+			m.recordExitLocked(s, code)
+		} else if !tmuxSet[s.TmuxName] {
+			// Exited while the gateway was down
+			m.markExitedLocked(s)
 		} else {
 			m.sessions[id].Status = StatusRunning
 			m.sessions[id].LastSeenAt = time.Now()
-			// Restart ttyd for recovered running sessions
-			if s.TtydPort > 0 {
-				if err := m.ttyd.Start(s.TmuxName, s.TtydPort); err != nil {
+			if s.LastActivityAt.IsZero() {
+				s.LastActivityAt = time.Now()
+			}
+			m.startTapLocked(id, s.TmuxName)
+			if m.builtinTerminal() {
+				// The built-in terminal needs no per-session process
+				m.sessions[id].TtydPort = 0
+				m.sessions[id].TerminalURL = fmt.Sprintf("/t/%s/", id)
+			} else if s.TtydPort > 0 {
+				// Restart ttyd for recovered running sessions
+				if err := m.startTtyd(s.TmuxName, s.TtydPort); err != nil {
 					log.Printf("sessions: failed to restart ttyd for %q: %v", s.TmuxName, err)
 					m.sessions[id].TtydPort = 0
 					m.sessions[id].TerminalURL = ""
+				} else {
+					m.emit(EventTtydRestarted, s, "")
 				}
 			}
+			m.emit(EventRecovered, s, "")
 		}
 	}
 
 	// Discover tmux sessions matching our prefix not yet tracked
-	for _, name := range tmuxSessions {
+	for name := range tmuxSet {
 		if !strings.HasPrefix(name, m.cfg.TmuxPrefix) {
 			continue
 		}
@@ -116,9 +164,11 @@ func (m *Manager) Recover() error {
 			id := name
 			var port int
 			var terminalURL string
-			if m.ttyd.Available() {
+			if m.builtinTerminal() {
+				terminalURL = fmt.Sprintf("/t/%s/", id)
+			} else if m.ttydAvailable() {
 				if p, err := m.ttyd.AllocatePort(); err == nil {
-					if startErr := m.ttyd.Start(name, p); startErr == nil {
+					if startErr := m.startTtyd(name, p); startErr == nil {
 						port = p
 						terminalURL = fmt.Sprintf("/t/%s/", id)
 					} else {
@@ -135,108 +185,119 @@ func (m *Manager) Recover() error {
 				CreatedAt:   time.Now(),
 				LastSeenAt:  time.Now(),
 				TerminalURL: terminalURL,
+				Record:      m.cfg.RecordSessions,
+				AgentState:  AgentUnknown,
+
+				LastActivityAt: time.Now(),
 			}
+			m.startTapLocked(id, name)
+			m.emit(EventRecovered, m.sessions[id], "")
 		}
 	}
 
 	return nil
 }
 
-// List returns all sessions with refreshed status.
-// Returns copies so callers cannot observe concurrent mutations.
-// Snapshots tmux names under lock, checks tmux outside the lock to avoid
-// blocking other operations behind slow shell invocations.
-func (m *Manager) List() []*Session {
-	// Snapshot IDs and tmux names under read lock
+// List returns the sessions matching q, in q's order, with the status last
+// seen by RunMonitor. Returns copies so callers cannot observe concurrent
+// mutations.
+func (m *Manager) List(q ListQuery) []*Session {
 	m.mu.RLock()
-	type entry struct {
-		id       string
-		tmuxName string
-	}
-	entries := make([]entry, 0, len(m.sessions))
-	for id, s := range m.sessions {
-		entries = append(entries, entry{id: id, tmuxName: s.TmuxName})
-	}
-	m.mu.RUnlock()
-
-	// Check tmux status without holding the lock
-	alive := make(map[string]bool, len(entries))
-	for _, e := range entries {
-		alive[e.id] = m.tmux.HasSession(e.tmuxName)
-	}
-
-	// Build lookup of IDs that were in the snapshot
-	snapshotIDs := make(map[string]bool, len(entries))
-	for _, e := range entries {
-		snapshotIDs[e.id] = true
-	}
-
-	// Re-lock to update status and build result
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	now := time.Now()
+	defer m.mu.RUnlock()
 	result := make([]*Session, 0, len(m.sessions))
-	for id, s := range m.sessions {
-		// Sessions created after the snapshot weren't checked — leave their status as-is
-		if !snapshotIDs[id] {
-			copy := *s
-			result = append(result, &copy)
+	for _, s := range m.sessions {
+		if !q.matches(s) {
 			continue
 		}
-		if alive[id] {
-			s.Status = StatusRunning
-			s.LastSeenAt = now
-		} else {
-			s.Status = StatusExited
-		}
 		copy := *s
+		copy.FinalScreen = ""
 		result = append(result, &copy)
 	}
+	slices.SortFunc(result, q.compare)
 	return result
 }
 
+// matches reports whether s passes every filter of q.
+func (q *ListQuery) matches(s *Session) bool {
+	if q.Status != "" && s.Status != q.Status {
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !slices.Contains(s.Tags, strings.ToLower(tag)) {
+			return false
+		}
+	}
+	if q.CWDPrefix != "" {
+		prefix := strings.TrimSuffix(filepath.Clean(q.CWDPrefix), string(filepath.Separator))
+		if s.CWD != prefix && !strings.HasPrefix(s.CWD, prefix+string(filepath.Separator)) {
+			return false
+		}
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		fields := append([]string{s.Name, s.ID, s.CWD, s.Notes}, s.Tags...)
+		if !slices.ContainsFunc(fields, func(f string) bool { return strings.Contains(strings.ToLower(f), text) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// compare orders two sessions by q.Sort, then by ID.
+func (q *ListQuery) compare(a, b *Session) int {
+	var c int
+	switch q.Sort {
+	case SortName:
+		c = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	case SortLastSeenAt:
+		c = b.LastSeenAt.Compare(a.LastSeenAt)
+	default:
+		c = b.CreatedAt.Compare(a.CreatedAt)
+	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
 // Get returns a session by ID.
 // Returns a copy so callers cannot observe concurrent mutations.
 func (m *Manager) Get(id string) (*Session, bool) {
-	// Read tmux name under read lock
 	m.mu.RLock()
+	defer m.mu.RUnlock()
 	s, ok := m.sessions[id]
 	if !ok {
-		m.mu.RUnlock()
 		return nil, false
 	}
-	tmuxName := s.TmuxName
-	m.mu.RUnlock()
-
-	// Check tmux without holding the lock
-	isAlive := m.tmux.HasSession(tmuxName)
-
-	// Re-lock to update and copy
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	s, ok = m.sessions[id]
-	if !ok {
-		return nil, false
-	}
-	if isAlive {
-		s.Status = StatusRunning
-		s.LastSeenAt = time.Now()
-	} else {
-		s.Status = StatusExited
-	}
 	copy := *s
 	return &copy, true
 }
 
 type CreateRequest struct {
-	Name     string `json:"name"`
-	CWD      string `json:"cwd"`
-	StartCmd string `json:"start_cmd"`
+	Name     string            `json:"name"`
+	CWD      string            `json:"cwd"`
+	StartCmd string            `json:"start_cmd"`
+	Env      map[string]string `json:"env,omitempty"`
+	Record   *bool             `json:"record,omitempty"` // nil uses config record_sessions
+	Tags     []string          `json:"tags,omitempty"`
+	// Template names a config template filling the fields left empty;
+	// its env is merged under Env.
+	Template string `json:"template,omitempty"`
+	// Prompt is typed into the session once its program is ready for input.
+	Prompt string `json:"prompt,omitempty"`
+	// IdleTimeout and MaxLifetime override idle_timeout and max_lifetime;
+	// "0s" disables the limit for this session.
+	IdleTimeout *Duration `json:"idle_timeout,omitempty"`
+	MaxLifetime *Duration `json:"max_lifetime,omitempty"`
 }
 
 // Create creates a new session.
 func (m *Manager) Create(req CreateRequest) (*Session, error) {
+	req, tplEnv, err := m.applyTemplate(req)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate inputs outside the lock — no shared state needed
 	if !m.cfg.IsPathAllowed(req.CWD) {
 		return nil, fmt.Errorf("path %q is not in allowed list", req.CWD)
@@ -248,6 +309,28 @@ func (m *Manager) Create(req CreateRequest) (*Session, error) {
 	if req.StartCmd == "" {
 		req.StartCmd = "claude"
 	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if req.IdleTimeout != nil && *req.IdleTimeout < 0 || req.MaxLifetime != nil && *req.MaxLifetime < 0 {
+		return nil, fmt.Errorf("idle_timeout and max_lifetime must not be negative")
+	}
+	for name := range req.Env {
+		if !m.cfg.IsEnvAllowed(name) {
+			return nil, fmt.Errorf("environment variable %q is not in allowed list", name)
+		}
+	}
+	env := m.cfg.ProjectEnvFor(req.CWD)
+	for name, value := range tplEnv {
+		env[name] = value
+	}
+	for name, value := range req.Env {
+		env[name] = value
+	}
+	if len(env) == 0 {
+		env = nil
+	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -273,22 +356,24 @@ func (m *Manager) Create(req CreateRequest) (*Session, error) {
 	}
 
 	// Create tmux session
-	if err := m.tmux.CreateSession(tmuxName, req.CWD, req.StartCmd); err != nil {
+	if err := m.mux.CreateSession(tmuxName, req.CWD, req.StartCmd, env); err != nil {
 		return nil, fmt.Errorf("create tmux session: %w", err)
 	}
 
-	// Allocate ttyd port only when ttyd is available
+	// Allocate ttyd port only when ttyd is the backend and available
 	var port int
 	var terminalURL string
-	if m.ttyd.Available() {
+	if m.builtinTerminal() {
+		terminalURL = fmt.Sprintf("/t/%s/", id)
+	} else if m.ttydAvailable() {
 		p, err := m.ttyd.AllocatePort()
 		if err != nil {
-			_ = m.tmux.KillSession(tmuxName)
+			_ = m.mux.KillSession(tmuxName)
 			return nil, fmt.Errorf("allocate port: %w", err)
 		}
-		if err := m.ttyd.Start(tmuxName, p); err != nil {
+		if err := m.startTtyd(tmuxName, p); err != nil {
 			m.ttyd.ReleasePort(p)
-			_ = m.tmux.KillSession(tmuxName)
+			_ = m.mux.KillSession(tmuxName)
 			return nil, fmt.Errorf("start ttyd: %w", err)
 		}
 		port = p
@@ -300,19 +385,121 @@ func (m *Manager) Create(req CreateRequest) (*Session, error) {
 		Name:        req.Name,
 		CWD:         req.CWD,
 		StartCmd:    req.StartCmd,
+		Env:         env,
 		CreatedAt:   now,
 		LastSeenAt:  now,
 		TmuxName:    tmuxName,
 		TtydPort:    port,
 		Status:      StatusRunning,
 		TerminalURL: terminalURL,
+		Record:      m.cfg.RecordSessions,
+		AgentState:  AgentUnknown,
+
+		Tags:           tags,
+		Template:       req.Template,
+		LastActivityAt: now,
+		IdleTimeout:    req.IdleTimeout,
+		MaxLifetime:    req.MaxLifetime,
+	}
+	if req.Record != nil {
+		s.Record = *req.Record
+	}
+	if cols, rows, err := m.mux.WindowSize(tmuxName); err == nil {
+		s.Cols, s.Rows = cols, rows
 	}
 
 	m.sessions[id] = s
+	m.startTapLocked(id, tmuxName)
 	m.saveToFile()
+	m.emit(EventCreated, s, "")
+	if req.Prompt != "" {
+		go m.sendInitialPrompt(id, req.Prompt)
+	}
 	return s, nil
 }
 
+// markExitedLocked records that a session's tmux session is gone and emits
+// EventStatusChanged and EventExited on the running -> exited transition.
+// Caller must hold m.mu.
+func (m *Manager) markExitedLocked(s *Session) {
+	prevStatus := s.Status
+	prevAgent := s.AgentState
+	s.Status = StatusExited
+	s.AgentState = AgentExited
+	m.stopTapLocked(s.ID)
+	if prevStatus != StatusExited {
+		now := time.Now()
+		s.ExitedAt = &now
+		m.emit(EventStatusChanged, s, string(prevStatus))
+		m.emit(EventExited, s, string(prevAgent))
+	}
+}
+
+// recordExitLocked marks a session whose panes have all exited, but which
+// the multiplexer still keeps, as exited, saving its exit status and final
+// screen first. The dead session is then killed so it does not linger in
+// the multiplexer. Caller must hold m.mu.
+func (m *Manager) recordExitLocked(s *Session, code *int) {
+	if s.Status != StatusExited {
+		screen, err := m.finalScreen(s.TmuxName)
+		if err != nil {
+			log.Printf("sessions: capture final screen of %q: %v", s.TmuxName, err)
+		}
+		s.ExitCode = code
+		s.FinalScreen = screen
+		m.markExitedLocked(s)
+		m.saveToFile()
+	}
+	if err := m.mux.KillSession(s.TmuxName); err != nil {
+		log.Printf("sessions: kill exited session %q: %v", s.TmuxName, err)
+	}
+}
+
+// finalScreen returns the last screenful of a finished pane's output.
+// tmux writes its "Pane is dead" notice below the output, scrolling it up
+// into the history, so one screen of history is captured as well and the
+// notice and trailing blank lines are dropped.
+func (m *Manager) finalScreen(name string) (string, error) {
+	_, height, err := m.mux.PaneSize(name)
+	if err != nil {
+		return "", err
+	}
+	lines, err := m.mux.CaptureRange(name, -height, height-1)
+	if err != nil {
+		return "", err
+	}
+	if n := len(lines); n > 0 && strings.HasPrefix(lines[n-1], "Pane is dead") {
+		lines = lines[:n-1]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	lines = lines[max(len(lines)-height, 0):]
+	return strings.Join(lines, "\n"), nil
+}
+
+// liveSessions lists the multiplexer's sessions: those still running, and
+// those whose command exited but which it keeps, with their exit status.
+func (m *Manager) liveSessions() (alive map[string]bool, exited map[string]*int, err error) {
+	names, err := m.mux.ListSessions()
+	if err != nil {
+		return nil, nil, err
+	}
+	if reporter, ok := m.mux.(ExitReporter); ok {
+		if exited, err = reporter.ExitedSessions(); err != nil {
+			// Only the final screens are lost; liveness is still known
+			log.Printf("sessions: list exited sessions: %v", err)
+		}
+	}
+	alive = make(map[string]bool, len(names))
+	for _, name := range names {
+		if _, ok := exited[name]; !ok {
+			alive[name] = true
+		}
+	}
+	return alive, exited, nil
+}
+
 // Kill stops a session.
 func (m *Manager) Kill(id string) error {
 	m.mu.Lock()
@@ -324,51 +511,377 @@ func (m *Manager) Kill(id string) error {
 	}
 
 	m.ttyd.Stop(s.TmuxName)
-	if err := m.tmux.KillSession(s.TmuxName); err != nil {
+	m.stopTapLocked(id)
+	if err := m.mux.KillSession(s.TmuxName); err != nil {
 		log.Printf("sessions: kill tmux %q: %v", s.TmuxName, err)
 	}
 	delete(m.sessions, id)
 	m.saveToFile()
+
+	prev := s.Status
+	s.Status = StatusExited
+	s.AgentState = AgentExited
+	m.emit(EventKilled, s, string(prev))
 	return nil
 }
 
-// SendText sends text input to a session.
-func (m *Manager) SendText(id, text string) error {
+// SendText sends text input to a session. target selects a window or pane
+// ("1", "1.0"); empty means the active pane.
+func (m *Manager) SendText(id, target, text string) error {
 	m.mu.RLock()
 	s, ok := m.sessions[id]
 	m.mu.RUnlock()
 	if !ok {
 		return &notFoundError{id: id}
 	}
-	return m.tmux.SendKeys(s.TmuxName, text)
+	dest, err := m.inputTarget(id, s.TmuxName, target)
+	if err != nil {
+		return err
+	}
+	if err := m.mux.SendKeys(dest, text); err != nil {
+		return err
+	}
+	m.emitInput(s, "text")
+	return nil
 }
 
-// Interrupt sends Ctrl+C to a session.
-func (m *Manager) Interrupt(id string) error {
+// Paste pastes text into a session's active pane or the given target as
+// one bracketed paste, pressing Enter afterwards if enter is set.
+func (m *Manager) Paste(id, target, text string, enter bool) error {
 	m.mu.RLock()
 	s, ok := m.sessions[id]
 	m.mu.RUnlock()
 	if !ok {
 		return &notFoundError{id: id}
 	}
-	return m.tmux.Interrupt(s.TmuxName)
+	paster, ok := m.mux.(Paster)
+	if !ok {
+		return fmt.Errorf("paste: %w", ErrUnsupported)
+	}
+	dest, err := m.inputTarget(id, s.TmuxName, target)
+	if err != nil {
+		return err
+	}
+	if err := paster.Paste(dest, text, enter); err != nil {
+		return err
+	}
+	m.emitInput(s, "paste")
+	return nil
+}
+
+// Resize sets the screen size of a session's current window, or of the
+// window holding target, and records the resulting size on the session.
+func (m *Manager) Resize(id, target string, cols, rows int) (*Session, error) {
+	m.mu.RLock()
+	s, ok := m.sessions[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, &notFoundError{id: id}
+	}
+	resizer, ok := m.mux.(Resizer)
+	if !ok {
+		return nil, fmt.Errorf("resize: %w", ErrUnsupported)
+	}
+	dest, err := m.inputTarget(id, s.TmuxName, target)
+	if err != nil {
+		return nil, err
+	}
+	if err := resizer.ResizeWindow(dest, cols, rows); err != nil {
+		return nil, err
+	}
+	cols, rows, err = m.mux.WindowSize(dest)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.Cols, s.Rows = cols, rows
+	m.saveToFile()
+	cp := *s
+	return &cp, nil
 }
 
-// SendKeys sends raw key tokens to a session.
-func (m *Manager) SendKeys(id string, keys []string) error {
+// Interrupt sends Ctrl+C to a session's active pane or the given target.
+func (m *Manager) Interrupt(id, target string) error {
 	m.mu.RLock()
 	s, ok := m.sessions[id]
 	m.mu.RUnlock()
 	if !ok {
 		return &notFoundError{id: id}
 	}
+	dest, err := m.inputTarget(id, s.TmuxName, target)
+	if err != nil {
+		return err
+	}
+	if err := m.mux.Interrupt(dest); err != nil {
+		return err
+	}
+	m.emitInput(s, "interrupt")
+	return nil
+}
 
-	// Map friendly names to tmux key tokens
-	mapped := make([]string, len(keys))
-	for i, k := range keys {
-		mapped[i] = mapKey(k)
+// SendKeys sends raw key tokens to a session's active pane or the given target.
+func (m *Manager) SendKeys(id, target string, keys []string) error {
+	m.mu.RLock()
+	s, ok := m.sessions[id]
+	m.mu.RUnlock()
+	if !ok {
+		return &notFoundError{id: id}
+	}
+	dest, err := m.inputTarget(id, s.TmuxName, target)
+	if err != nil {
+		return err
+	}
+
+	mapped, err := ParseKeys(keys)
+	if err != nil {
+		return err
+	}
+	if err := m.mux.SendRawKeys(dest, mapped); err != nil {
+		return err
+	}
+	m.emitInput(s, "keys")
+	return nil
+}
+
+// CaptureScreen returns the currently visible pane of a session.
+// With escapes set, the result keeps ANSI color and attribute sequences.
+func (m *Manager) CaptureScreen(id string, escapes bool) (string, error) {
+	m.mu.RLock()
+	s, ok := m.sessions[id]
+	m.mu.RUnlock()
+	if !ok {
+		return "", &notFoundError{id: id}
+	}
+	return m.mux.CapturePane(s.TmuxName, escapes)
+}
+
+// History returns a page of a session's scrollback (history plus the visible pane).
+func (m *Manager) History(id string, q HistoryQuery) (*History, error) {
+	m.mu.RLock()
+	s, ok := m.sessions[id]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, &notFoundError{id: id}
+	}
+
+	histSize, height, err := m.mux.PaneSize(s.TmuxName)
+	if err != nil {
+		return nil, err
+	}
+	total := histSize + height
+
+	from := q.From
+	if from < 0 {
+		from = total - q.Lines
+	}
+	if from < 0 {
+		from = 0
+	}
+	result := &History{Total: total, From: from, Lines: []HistoryLine{}}
+	if from >= total {
+		return result, nil
+	}
+
+	// Line N maps to tmux line N-histSize (negative numbers are scrollback).
+	// Searching scans everything from the start offset; paging reads just the window.
+	end := total - 1
+	if q.Pattern == nil && from+q.Lines-1 < end {
+		end = from + q.Lines - 1
+	}
+	lines, err := m.mux.CaptureRange(s.TmuxName, from-histSize, end-histSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, text := range lines {
+		if len(result.Lines) >= q.Lines {
+			break
+		}
+		if q.Pattern != nil && !q.Pattern.MatchString(text) {
+			continue
+		}
+		result.Lines = append(result.Lines, HistoryLine{N: from + i, Text: text})
+	}
+	return result, nil
+}
+
+// OutputStream returns the live output buffer of a running session.
+// The tap is started on demand if it is not running yet (e.g. after it failed on create).
+func (m *Manager) OutputStream(id string) (*OutputBuffer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, &notFoundError{id: id}
+	}
+	if tap, ok := m.taps[id]; ok {
+		return tap.buf, nil
+	}
+	if s.Status == StatusExited {
+		return nil, fmt.Errorf("session %q: %w", id, ErrSessionExited)
+	}
+	if !m.startTapLocked(id, s.TmuxName) {
+		return nil, fmt.Errorf("output stream for %q is unavailable", id)
+	}
+	return m.taps[id].buf, nil
+}
+
+// startTapLocked starts streaming pane output for a session into a ring buffer.
+// Failures are logged; the session keeps working without a live stream.
+// Caller must hold m.mu.
+func (m *Manager) startTapLocked(id, tmuxName string) bool {
+	if _, ok := m.taps[id]; ok {
+		return true
+	}
+	if m.pipeDir == "" {
+		dir, err := os.MkdirTemp("", "cc-web-pipes-")
+		if err != nil {
+			log.Printf("sessions: create pipe dir: %v", err)
+			return false
+		}
+		m.pipeDir = dir
+	}
+	bufKB := m.cfg.StreamBufferKB
+	if bufKB <= 0 {
+		bufKB = 256
+	}
+	piper, ok := m.mux.(OutputPiper)
+	if !ok {
+		return false
+	}
+	tap, err := startTap(piper, m.pipeDir, tmuxName, bufKB*1024)
+	if err != nil {
+		log.Printf("sessions: start output tap for %q: %v", tmuxName, err)
+		return false
+	}
+	m.taps[id] = tap
+
+	if s, ok := m.sessions[id]; ok && s.Record {
+		m.startRecorderLocked(s, tap.buf)
+	}
+	return true
+}
+
+// startRecorderLocked starts an asciicast recording of a session's output.
+// Failures are logged; the session keeps running unrecorded. Caller must hold m.mu.
+func (m *Manager) startRecorderLocked(s *Session, buf *OutputBuffer) {
+	cols, rows, err := m.mux.WindowSize(s.TmuxName)
+	if err != nil {
+		cols, rows = 80, 24
 	}
-	return m.tmux.SendRawKeys(s.TmuxName, mapped)
+	screen, err := m.mux.CapturePane(s.TmuxName, true)
+	if err != nil {
+		screen = ""
+	}
+	rec, err := startRecorder(m.recordingsDir(s.ID), s.Name, buf, cols, rows, screen)
+	if err != nil {
+		log.Printf("sessions: start recording for %q: %v", s.TmuxName, err)
+		return
+	}
+	m.recs[s.ID] = rec
+}
+
+// recordingsDir returns the directory holding a session's recordings.
+func (m *Manager) recordingsDir(id string) string {
+	return filepath.Join(m.cfg.RecordingsDir, id)
+}
+
+// ListRecordings returns the asciicast recordings of a session, oldest first.
+// Recordings outlive their session, so this also works after a kill.
+func (m *Manager) ListRecordings(id string) ([]Recording, error) {
+	if !safeIDPattern.MatchString(id) {
+		return nil, &notFoundError{id: id}
+	}
+	m.mu.RLock()
+	_, known := m.sessions[id]
+	rec := m.recs[id]
+	m.mu.RUnlock()
+
+	list, err := listRecordings(m.recordingsDir(id))
+	if err != nil {
+		return nil, err
+	}
+	if !known && len(list) == 0 {
+		return nil, &notFoundError{id: id}
+	}
+	for i := range list {
+		list[i].Active = rec != nil && rec.name == list[i].Name && rec.active()
+	}
+	return list, nil
+}
+
+// RecordingFile returns the path of a recording and whether it is still being written.
+func (m *Manager) RecordingFile(id, name string) (path string, active bool, err error) {
+	if !safeIDPattern.MatchString(id) || !recordingNamePattern.MatchString(name) {
+		return "", false, fmt.Errorf("recording %q of session %q: %w", name, id, ErrNotFound)
+	}
+	path = filepath.Join(m.recordingsDir(id), name)
+	if _, err := os.Stat(path); err != nil {
+		return "", false, fmt.Errorf("recording %q of session %q: %w", name, id, ErrNotFound)
+	}
+	m.mu.RLock()
+	rec := m.recs[id]
+	m.mu.RUnlock()
+	return path, rec != nil && rec.name == name && rec.active(), nil
+}
+
+// stopTapLocked stops the output tap of a session, if any. Caller must hold m.mu.
+// Stopping the tap also ends any recording fed by it.
+func (m *Manager) stopTapLocked(id string) {
+	if tap, ok := m.taps[id]; ok {
+		tap.stop()
+		delete(m.taps, id)
+	}
+	delete(m.recs, id)
+}
+
+// AttachTerminal attaches a new tmux client to a running session under a
+// pseudo-terminal of the given size, for the built-in WebSocket terminal.
+// The caller must Close the returned Terminal.
+func (m *Manager) AttachTerminal(id string, cols, rows int) (*Terminal, error) {
+	m.mu.RLock()
+	s, ok := m.sessions[id]
+	var tmuxName string
+	var status Status
+	if ok {
+		tmuxName, status = s.TmuxName, s.Status
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return nil, &notFoundError{id: id}
+	}
+	if status == StatusExited {
+		return nil, fmt.Errorf("session %q: %w", id, ErrSessionExited)
+	}
+	attacher, ok := m.mux.(Attacher)
+	if !ok {
+		return nil, fmt.Errorf("the multiplexer backend does not support attaching to sessions")
+	}
+	return attachTerminal(attacher.AttachCommand(tmuxName), cols, rows)
+}
+
+// builtinTerminal reports whether the gateway serves terminals itself instead of ttyd.
+func (m *Manager) builtinTerminal() bool {
+	return m.cfg.TerminalBackend == config.TerminalBuiltin
+}
+
+// ttydAvailable reports whether sessions can get a ttyd terminal: ttyd is
+// installed and the multiplexer supports attaching.
+func (m *Manager) ttydAvailable() bool {
+	_, ok := m.mux.(Attacher)
+	return ok && m.ttyd.Available()
+}
+
+// startTtyd starts ttyd on port, attached to the named session.
+func (m *Manager) startTtyd(tmuxName string, port int) error {
+	attacher, ok := m.mux.(Attacher)
+	if !ok {
+		return fmt.Errorf("the multiplexer backend does not support attaching to sessions")
+	}
+	return m.ttyd.Start(tmuxName, port, attacher.AttachCommand(tmuxName))
 }
 
 // GetTtydPort returns the ttyd port for a session (for proxying).
@@ -382,14 +895,37 @@ func (m *Manager) GetTtydPort(id string) (int, bool) {
 	return s.TtydPort, true
 }
 
-// Cleanup stops all ttyd processes. Called during graceful shutdown.
+// Cleanup stops all ttyd processes and the tmux control-mode connection.
+// Called during graceful shutdown.
 // tmux sessions are left alive so they persist across gateway restarts.
 func (m *Manager) Cleanup() {
 	m.ttyd.StopAll()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id := range m.taps {
+		m.stopTapLocked(id)
+	}
+	if m.pipeDir != "" {
+		_ = os.RemoveAll(m.pipeDir)
+	}
+	if tmux, ok := m.mux.(*TmuxRunner); ok {
+		tmux.StopControl()
+	}
+}
+
+// storedSession is a Session as saved in sessions_file, with its Env.
+type storedSession struct {
+	*Session
+	Env map[string]string `json:"env,omitempty"`
 }
 
 func (m *Manager) saveToFile() {
-	data, err := json.MarshalIndent(m.sessions, "", "  ")
+	stored := make(map[string]storedSession, len(m.sessions))
+	for id, s := range m.sessions {
+		stored[id] = storedSession{Session: s, Env: s.Env}
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
 	if err != nil {
 		log.Printf("sessions: marshal error: %v", err)
 		return
@@ -399,6 +935,8 @@ func (m *Manager) saveToFile() {
 	}
 }
 
+// loadFromFile replaces the sessions with those saved in sessions_file.
+// The map is built first and swapped in under m.mu.
 func (m *Manager) loadFromFile() {
 	data, err := os.ReadFile(m.cfg.SessionsFile)
 	if err != nil {
@@ -407,15 +945,22 @@ func (m *Manager) loadFromFile() {
 		}
 		return
 	}
-	var sessions map[string]*Session
-	if err := json.Unmarshal(data, &sessions); err != nil {
+	var stored map[string]storedSession
+	if err := json.Unmarshal(data, &stored); err != nil {
 		log.Printf("sessions: corrupt sessions file: %v", err)
 		return
 	}
-	if sessions == nil {
-		sessions = make(map[string]*Session)
+	sessions := make(map[string]*Session, len(stored))
+	for id, st := range stored {
+		if st.Session == nil {
+			continue
+		}
+		st.Session.Env = st.Env
+		sessions[id] = st.Session
 	}
+	m.mu.Lock()
 	m.sessions = sessions
+	m.mu.Unlock()
 }
 
 func sanitizeName(name string) string {
@@ -442,36 +987,3 @@ func randomSuffix() string {
 	}
 	return hex.EncodeToString(b)
 }
-
-func mapKey(key string) string {
-	switch strings.ToUpper(key) {
-	case "ESC", "ESCAPE":
-		return "Escape"
-	case "UP":
-		return "Up"
-	case "DOWN":
-		return "Down"
-	case "LEFT":
-		return "Left"
-	case "RIGHT":
-		return "Right"
-	case "TAB":
-		return "Tab"
-	case "ENTER", "RETURN":
-		return "Enter"
-	case "CTRL_C", "CTRL+C":
-		return "C-c"
-	case "CTRL_D", "CTRL+D":
-		return "C-d"
-	case "CTRL_Z", "CTRL+Z":
-		return "C-z"
-	case "CTRL_L", "CTRL+L":
-		return "C-l"
-	case "BACKSPACE":
-		return "BSpace"
-	case "SPACE":
-		return "Space"
-	default:
-		return key
-	}
-}
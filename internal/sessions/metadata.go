@@ -0,0 +1,126 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidMetadata is returned by UpdateMetadata for a value it rejects.
+var ErrInvalidMetadata = errors.New("invalid session metadata")
+
+// Limits on the editable metadata, in characters.
+const (
+	maxNameLen  = 100
+	maxNotesLen = 4000
+	maxEmojiLen = 8
+)
+
+// colorPattern matches the #rrggbb colors a session may be labelled with.
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// tagPattern matches a tag after normalizeTags has lower-cased it.
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._:/-]{0,39}$`)
+
+// maxTags bounds the number of tags on one session.
+const maxTags = 20
+
+// MetadataUpdate changes the user-facing labels of a session. Nil fields
+// are left as they are; an empty Notes, Color or Emoji clears it.
+type MetadataUpdate struct {
+	Name   *string `json:"name,omitempty"`
+	Notes  *string `json:"notes,omitempty"`
+	Color  *string `json:"color,omitempty"`
+	Emoji  *string `json:"emoji,omitempty"`
+	Pinned *bool   `json:"pinned,omitempty"`
+	// Tags replaces all tags; an empty list removes them.
+	Tags *[]string `json:"tags,omitempty"`
+}
+
+// validate checks the fields being set and trims the name.
+func (u *MetadataUpdate) validate() error {
+	if u.Name != nil {
+		name := strings.TrimSpace(*u.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxNameLen || strings.ContainsFunc(name, unicode.IsControl) {
+			return fmt.Errorf("%w: name must be 1-%d characters on one line", ErrInvalidMetadata, maxNameLen)
+		}
+		u.Name = &name
+	}
+	if u.Notes != nil && utf8.RuneCountInString(*u.Notes) > maxNotesLen {
+		return fmt.Errorf("%w: notes must be at most %d characters", ErrInvalidMetadata, maxNotesLen)
+	}
+	if u.Color != nil && *u.Color != "" && !colorPattern.MatchString(*u.Color) {
+		return fmt.Errorf("%w: color must be #rrggbb", ErrInvalidMetadata)
+	}
+	if u.Emoji != nil && (utf8.RuneCountInString(*u.Emoji) > maxEmojiLen || strings.ContainsFunc(*u.Emoji, unicode.IsSpace) || strings.ContainsFunc(*u.Emoji, unicode.IsControl)) {
+		return fmt.Errorf("%w: emoji must be at most %d characters without spaces", ErrInvalidMetadata, maxEmojiLen)
+	}
+	if u.Tags != nil {
+		tags, err := normalizeTags(*u.Tags)
+		if err != nil {
+			return err
+		}
+		u.Tags = &tags
+	}
+	return nil
+}
+
+// normalizeTags lower-cases and trims tags, drops duplicates and checks
+// them against tagPattern. The result is nil for no tags.
+func normalizeTags(tags []string) ([]string, error) {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("%w: tag %q must be 1-40 letters, digits or . _ : / -", ErrInvalidMetadata, tag)
+		}
+		if !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	if len(out) > maxTags {
+		return nil, fmt.Errorf("%w: at most %d tags", ErrInvalidMetadata, maxTags)
+	}
+	return out, nil
+}
+
+// UpdateMetadata applies u to a session's display name, notes, color,
+// emoji, pinned flag and tags. The tmux session and ID are not affected.
+func (m *Manager) UpdateMetadata(id string, u MetadataUpdate) (*Session, error) {
+	if err := u.validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, &notFoundError{id: id}
+	}
+	if u.Name != nil {
+		s.Name = *u.Name
+	}
+	if u.Notes != nil {
+		s.Notes = *u.Notes
+	}
+	if u.Color != nil {
+		s.Color = strings.ToLower(*u.Color)
+	}
+	if u.Emoji != nil {
+		s.Emoji = *u.Emoji
+	}
+	if u.Pinned != nil {
+		s.Pinned = *u.Pinned
+	}
+	if u.Tags != nil {
+		s.Tags = *u.Tags
+	}
+	m.saveToFile()
+	m.emit(EventUpdated, s, "")
+	cp := *s
+	return &cp, nil
+}
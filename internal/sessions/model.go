@@ -1,6 +1,11 @@
 package sessions
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
 
 type Status string
 
@@ -11,14 +16,151 @@ const (
 )
 
 type Session struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	CWD         string    `json:"cwd"`
-	StartCmd    string    `json:"start_cmd"`
-	CreatedAt   time.Time `json:"created_at"`
-	LastSeenAt  time.Time `json:"last_seen_at"`
-	TmuxName    string    `json:"tmux_name"`
-	TtydPort    int       `json:"ttyd_port"`
-	Status      Status    `json:"status"`
-	TerminalURL string    `json:"terminal_url"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	CWD         string     `json:"cwd"`
+	StartCmd    string     `json:"start_cmd"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	TmuxName    string     `json:"tmux_name"`
+	TtydPort    int        `json:"ttyd_port"`
+	Status      Status     `json:"status"`
+	TerminalURL string     `json:"terminal_url"`
+	Record      bool       `json:"record"`
+	AgentState  AgentState `json:"agent_state"`
+	// Notes, Color, Emoji, Pinned and Tags label the session in the UI;
+	// they and Name can be changed with UpdateMetadata.
+	Notes  string   `json:"notes,omitempty"`
+	Color  string   `json:"color,omitempty"` // "#rrggbb"
+	Emoji  string   `json:"emoji,omitempty"`
+	Pinned bool     `json:"pinned,omitempty"`
+	Tags   []string `json:"tags,omitempty"` // lower-case, see normalizeTags
+	// Template is the config template the session was created from.
+	Template string `json:"template,omitempty"`
+	// Env holds the variables the session was created with, on top of the
+	// gateway's own environment: project_env defaults and the request's env.
+	// The values may be secrets, so they are only written to sessions_file
+	// (see storedSession), never to API responses, events or webhooks.
+	Env map[string]string `json:"-"`
+	// ExitCode, ExitedAt and FinalScreen describe how a session ended.
+	// ExitCode and FinalScreen are only known for backends that keep
+	// finished sessions (see ExitReporter); FinalScreen is left out of List.
+	ExitCode    *int       `json:"exit_code,omitempty"`
+	ExitedAt    *time.Time `json:"exited_at,omitempty"`
+	FinalScreen string     `json:"final_screen,omitempty"`
+	// Cols and Rows are the screen size last seen by the gateway: at
+	// creation and after each resize.
+	Cols int `json:"cols,omitempty"`
+	Rows int `json:"rows,omitempty"`
+	// LastActivityAt is when the session last produced output or received
+	// input through the API; idle_timeout counts from it.
+	LastActivityAt time.Time `json:"last_activity_at"`
+	// IdleTimeout and MaxLifetime override the idle_timeout and
+	// max_lifetime settings for this session; nil uses the setting and
+	// zero disables the limit (see RunReaper).
+	IdleTimeout *Duration `json:"idle_timeout,omitempty"`
+	MaxLifetime *Duration `json:"max_lifetime,omitempty"`
+}
+
+// Duration is a time.Duration written in JSON as a string like "30m".
+// A JSON number is read as seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+// Sort orders for ListQuery.
+const (
+	SortCreatedAt  = "created_at"   // newest first
+	SortLastSeenAt = "last_seen_at" // most recently seen first
+	SortName       = "name"         // by name, case-insensitive
+)
+
+// ListQuery filters and orders List. Zero fields match every session.
+type ListQuery struct {
+	Status    Status
+	Tags      []string // sessions must have all of them
+	CWDPrefix string   // the cwd or a directory above it
+	// Text is matched case-insensitively against the name, ID, cwd, notes
+	// and tags.
+	Text string
+	// Sort is SortCreatedAt (the default), SortLastSeenAt or SortName.
+	// Ties are broken by ID, so the order is stable across calls.
+	Sort string
+}
+
+// HistoryLine is one line of a session's scrollback, numbered from the
+// oldest line still held by tmux.
+type HistoryLine struct {
+	N    int    `json:"n"`
+	Text string `json:"text"`
+}
+
+// HistoryQuery selects a page of scrollback. A negative From means
+// "the last Lines lines". A non-nil Pattern turns the page into a search:
+// only matching lines at or after From are returned, up to Lines of them.
+type HistoryQuery struct {
+	From    int
+	Lines   int
+	Pattern *regexp.Regexp
+}
+
+// History is a page of scrollback plus the total number of lines available.
+type History struct {
+	Total int           `json:"total"`
+	From  int           `json:"from"`
+	Lines []HistoryLine `json:"lines"`
+}
+
+// Window is a tmux window inside a session.
+type Window struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+	Panes  []Pane `json:"panes"`
+}
+
+// Pane is a pane inside a window. Target ("<window>.<pane>") addresses it
+// in the send, keys and interrupt APIs.
+type Pane struct {
+	Index   int    `json:"index"`
+	ID      string `json:"id"`
+	Target  string `json:"target"`
+	Active  bool   `json:"active"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Command string `json:"command"`
+}
+
+// WindowRequest describes a new window or pane. CWD defaults to the
+// session's directory and Cmd to the default shell.
+type WindowRequest struct {
+	Name   string `json:"name,omitempty"` // windows only
+	CWD    string `json:"cwd,omitempty"`
+	Cmd    string `json:"cmd,omitempty"`
+	Select bool   `json:"select,omitempty"`
+	// Horizontal splits side by side instead of top and bottom (panes only).
+	Horizontal bool `json:"horizontal,omitempty"`
 }
@@ -0,0 +1,146 @@
+package sessions
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunMonitor refreshes Status and LastSeenAt of all sessions every
+// monitor_interval until ctx is cancelled. Each pass costs a ListSessions
+// call (one `tmux list-sessions`, plus one `list-panes` for exit statuses),
+// so List and Get can serve the cached state.
+func (m *Manager) RunMonitor(ctx context.Context) {
+	interval := m.cfg.MonitorInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.refreshStatuses()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.refresh:
+		}
+	}
+}
+
+// controlRetryInterval is the wait before reconnecting control mode.
+const controlRetryInterval = 30 * time.Second
+
+// RunControlMode keeps a tmux control-mode connection open until ctx is
+// cancelled, reconnecting after failures. While it is down, tmux commands
+// are executed one process per call as before. Session changes reported
+// by tmux trigger an immediate status refresh in RunMonitor.
+//
+// Control mode needs a dedicated server (tmux_socket): its client attaches
+// to a helper session, which on the user's default server would show up
+// next to their own sessions.
+func (m *Manager) RunControlMode(ctx context.Context) {
+	tmux, ok := m.mux.(*TmuxRunner)
+	if !ok {
+		return
+	}
+	if !tmux.dedicated {
+		log.Printf("sessions: tmux control mode needs tmux_socket, using exec")
+		return
+	}
+	warned := false
+	for {
+		done, err := tmux.StartControl(m.onTmuxNotification)
+		if err != nil {
+			if !warned {
+				log.Printf("sessions: tmux control mode unavailable, using exec: %v", err)
+				warned = true
+			}
+		} else {
+			log.Printf("sessions: tmux control mode connected")
+			warned = false
+			select {
+			case <-ctx.Done():
+				tmux.StopControl()
+				return
+			case <-done:
+				log.Printf("sessions: tmux control mode disconnected, using exec")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(controlRetryInterval):
+		}
+	}
+}
+
+// onTmuxNotification runs on the control-mode reader goroutine, so it only
+// signals RunMonitor instead of issuing tmux commands itself.
+func (m *Manager) onTmuxNotification(n controlNotification) {
+	if n.Name == "sessions-changed" {
+		select {
+		case m.refresh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// refreshStatuses lists multiplexer sessions outside the lock and applies the
+// result, emitting an event for every status transition.
+func (m *Manager) refreshStatuses() {
+	before := m.statusSnapshot()
+	alive, exited, err := m.liveSessions()
+	if err != nil {
+		// Keep the cached state rather than marking everything exited
+		log.Printf("sessions: monitor: %v", err)
+		return
+	}
+	m.applyStatuses(before, alive, exited)
+}
+
+// statusSnapshot returns the status of every session, taken before the
+// multiplexer is listed.
+func (m *Manager) statusSnapshot() map[string]Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snap := make(map[string]Status, len(m.sessions))
+	for id, s := range m.sessions {
+		snap[id] = s.Status
+	}
+	return snap
+}
+
+// applyStatuses updates sessions from a multiplexer listing. Sessions
+// created, restarted or otherwise changed since the before snapshot are
+// skipped: the listing may predate them, and the next pass sees them.
+func (m *Manager) applyStatuses(before map[string]Status, alive map[string]bool, exited map[string]*int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, s := range m.sessions {
+		if status, ok := before[id]; !ok || status != s.Status {
+			continue
+		}
+		if code, ok := exited[s.TmuxName]; ok {
+			m.recordExitLocked(s, code)
+			continue
+		}
+		if !alive[s.TmuxName] {
+			m.markExitedLocked(s)
+			continue
+		}
+		s.LastSeenAt = now
+		if s.Status != StatusRunning {
+			prev := s.Status
+			s.Status = StatusRunning
+			if prev == StatusExited {
+				// The tap was stopped when the session was marked exited
+				s.ExitedAt = nil
+				s.AgentState = AgentUnknown
+				m.startTapLocked(id, s.TmuxName)
+			}
+			m.emit(EventStatusChanged, s, string(prev))
+		}
+	}
+}
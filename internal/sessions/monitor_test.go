@@ -0,0 +1,114 @@
+package sessions_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/user/cc-web/internal/config"
+	"github.com/user/cc-web/internal/sessions"
+	"github.com/user/cc-web/internal/sessions/sessionstest"
+)
+
+func TestRefreshStatuses_MarksMissingExited(t *testing.T) {
+	fake := sessionstest.NewFakeMultiplexer()
+	m := sessions.NewManagerWithMultiplexer(&config.Config{}, fake)
+	if err := fake.CreateSession("alive", "/", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	m.AddSession(&sessions.Session{ID: "alive", TmuxName: "alive", Status: sessions.StatusRunning})
+	m.AddSession(&sessions.Session{ID: "gone", TmuxName: "gone", Status: sessions.StatusRunning})
+
+	var types []sessions.EventType
+	m.Subscribe(func(ev sessions.Event) { types = append(types, ev.Type) })
+
+	m.RefreshStatuses()
+	s, ok := m.Get("gone")
+	if !ok || s.Status != sessions.StatusExited || s.AgentState != sessions.AgentExited {
+		t.Fatalf("session = %+v", s)
+	}
+	if len(types) != 2 || types[0] != sessions.EventStatusChanged || types[1] != sessions.EventExited {
+		t.Errorf("events = %v", types)
+	}
+	if s, _ := m.Get("alive"); s.Status != sessions.StatusRunning || s.LastSeenAt.IsZero() {
+		t.Errorf("alive session = %+v", s)
+	}
+
+	// A second pass is not a transition
+	m.RefreshStatuses()
+	if len(types) != 2 {
+		t.Errorf("events after second pass = %v", types)
+	}
+}
+
+func TestRefreshStatuses_RecordsExit(t *testing.T) {
+	fake := sessionstest.NewFakeMultiplexer()
+	m := sessions.NewManagerWithMultiplexer(&config.Config{
+		ProjectsAllowed: []string{"/"},
+		MaxSessions:     1,
+		SessionsFile:    filepath.Join(t.TempDir(), "sessions.json"),
+	}, fake)
+	if err := fake.CreateSession("done", "/", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	m.AddSession(&sessions.Session{ID: "done", TmuxName: "done", CWD: "/", Status: sessions.StatusRunning})
+	fake.SetScreen("done", "all tests passed\n")
+	fake.Finish("done", 3)
+
+	m.RefreshStatuses()
+	s, _ := m.Get("done")
+	if s.Status != sessions.StatusExited || s.ExitCode == nil || *s.ExitCode != 3 || s.ExitedAt == nil {
+		t.Fatalf("session = %+v", s)
+	}
+	if s.FinalScreen != "all tests passed" {
+		t.Errorf("final screen = %q", s.FinalScreen)
+	}
+	if list := m.List(sessions.ListQuery{}); list[0].FinalScreen != "" {
+		t.Errorf("List includes the final screen")
+	}
+	// The dead session is not left behind once recorded
+	if names, _ := fake.ListSessions(); len(names) != 0 {
+		t.Errorf("multiplexer sessions after the exit = %v", names)
+	}
+
+	s, err := m.Restart("done")
+	if err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	if s.Status != sessions.StatusRunning || s.ExitCode != nil || s.FinalScreen != "" {
+		t.Errorf("restarted session = %+v", s)
+	}
+	if names, _ := fake.ListSessions(); len(names) != 1 {
+		t.Errorf("multiplexer sessions after restart = %v", names)
+	}
+}
+
+func TestRefreshStatuses_SkipsSessionsChangedWhileListing(t *testing.T) {
+	fake := sessionstest.NewFakeMultiplexer()
+	m := sessions.NewManagerWithMultiplexer(&config.Config{
+		ProjectsAllowed: []string{"/"},
+		MaxSessions:     5,
+		SessionsFile:    filepath.Join(t.TempDir(), "sessions.json"),
+	}, fake)
+
+	// A session created after the snapshot is missing from the listing
+	before := m.StatusSnapshot()
+	alive, exited, err := m.LiveSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.Create(sessions.CreateRequest{Name: "new", CWD: t.TempDir(), StartCmd: "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ApplyStatuses(before, alive, exited)
+	if got, _ := m.Get(s.ID); got.Status != sessions.StatusRunning {
+		t.Errorf("new session marked %s", got.Status)
+	}
+
+	// A session seen alive again after being marked exited runs again
+	m.MarkExited(s.ID)
+	m.RefreshStatuses()
+	if got, _ := m.Get(s.ID); got.Status != sessions.StatusRunning || got.ExitedAt != nil {
+		t.Errorf("session = %+v", got)
+	}
+}
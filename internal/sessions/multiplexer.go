@@ -0,0 +1,106 @@
+package sessions
+
+import (
+	"fmt"
+
+	"github.com/user/cc-web/internal/config"
+)
+
+// Multiplexer is a terminal multiplexer backend hosting the sessions.
+// Session names are the Session.TmuxName values chosen by the Manager.
+type Multiplexer interface {
+	// CreateSession starts a detached session running startCmd (the
+	// default shell if empty) in cwd, with env added to its environment.
+	CreateSession(name, cwd, startCmd string, env map[string]string) error
+	KillSession(name string) error
+	// SendKeys types text literally and presses Enter.
+	SendKeys(name, text string) error
+	// SendRawKeys sends keys in tmux key-name syntax, as produced by
+	// ParseKeys; "0xNN" keys are raw bytes.
+	SendRawKeys(name string, keys []string) error
+	// Interrupt sends Ctrl+C.
+	Interrupt(name string) error
+	// ListSessions returns the names of all live sessions.
+	ListSessions() ([]string, error)
+
+	// CapturePane returns the visible screen; with escapes set, colors and
+	// attributes are kept as ANSI sequences where the backend supports it.
+	CapturePane(name string, escapes bool) (string, error)
+	// PaneSize returns the number of scrollback lines and the screen height.
+	PaneSize(name string) (history, height int, err error)
+	// CaptureRange returns lines start..end inclusive, numbered like tmux:
+	// 0 is the top visible line, negative numbers are scrollback.
+	CaptureRange(name string, start, end int) ([]string, error)
+	// WindowSize returns the screen width and height.
+	WindowSize(name string) (cols, rows int, err error)
+}
+
+// OutputPiper is implemented by backends that can copy a session's output,
+// as it is produced, into a file (the output tap's FIFO).
+type OutputPiper interface {
+	PipeOutput(name, path string) error
+}
+
+// Attacher is implemented by backends whose sessions can be attached to
+// interactively; the command is run by ttyd or the built-in terminal.
+type Attacher interface {
+	AttachCommand(name string) []string
+}
+
+// Paster is implemented by backends that can paste text in one piece, with
+// bracketed paste so multi-line text is not submitted line by line, and
+// without argv size limits. With enter set, Enter is pressed afterwards.
+type Paster interface {
+	Paste(name, text string, enter bool) error
+}
+
+// ExitReporter is implemented by backends that keep a session whose
+// command exited (tmux remain-on-exit), so its final screen can still be
+// captured. ListSessions includes such sessions.
+type ExitReporter interface {
+	// ExitedSessions maps each session whose panes have all exited to
+	// the exit status of its first pane, or nil if that is unknown.
+	ExitedSessions() (map[string]*int, error)
+}
+
+// Respawner is implemented by backends that can keep a session whose
+// command exited (tmux remain-on-exit) and run the command again.
+type Respawner interface {
+	// RespawnDeadPanes restarts every dead pane of a session and returns
+	// how many there were.
+	RespawnDeadPanes(name string) (int, error)
+}
+
+// Resizer is implemented by backends whose screen size can be set from
+// outside, independently of the attached clients. name may also be
+// "<name>:<target>" for backends that are a WindowManager.
+type Resizer interface {
+	ResizeWindow(name string, cols, rows int) error
+}
+
+// WindowManager is implemented by backends with windows and panes inside
+// a session. target is "<window>" or "<window>.<pane>" (see ValidTarget).
+// For these backends the Multiplexer input methods also accept
+// "<name>:<target>" in place of a session name.
+type WindowManager interface {
+	ListWindows(name string) ([]Window, error)
+	// NewWindow creates a window without selecting it and returns its index.
+	NewWindow(name, windowName, cwd, cmd string) (int, error)
+	// SplitPane splits the active pane of a window without selecting the
+	// new pane and returns the new pane's target.
+	SplitPane(name, window string, horizontal bool, cwd, cmd string) (string, error)
+	SelectTarget(name, target string) error
+	KillTarget(name, target string) error
+}
+
+// newMultiplexer returns the backend selected by the multiplexer setting.
+func newMultiplexer(cfg *config.Config) (Multiplexer, error) {
+	switch cfg.Multiplexer {
+	case "", config.MultiplexerTmux:
+		return NewTmuxRunnerFromConfig(cfg), nil
+	case config.MultiplexerScreen:
+		return NewScreenRunner(), nil
+	default:
+		return nil, fmt.Errorf("unknown multiplexer %q", cfg.Multiplexer)
+	}
+}
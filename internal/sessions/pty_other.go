@@ -0,0 +1,19 @@
+//go:build !linux && !darwin
+
+package sessions
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errNoPTY = errors.New("pseudo-terminals are not supported on this platform")
+
+func startInPTY(cmd *exec.Cmd, cols, rows int) (*os.File, error) {
+	return nil, errNoPTY
+}
+
+func setWinsize(f *os.File, cols, rows int) error {
+	return errNoPTY
+}
@@ -0,0 +1,191 @@
+package sessions
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Reasons the reaper stops a session, in Event.Detail.
+const (
+	ReapIdle        = "idle"
+	ReapMaxLifetime = "max_lifetime"
+)
+
+// reapInterval is how often RunReaper checks the session limits.
+const reapInterval = 10 * time.Second
+
+// reapStage is how far the reaper has gone in stopping a session.
+type reapStage int
+
+const (
+	reapNone reapStage = iota
+	reapWarned
+	reapInterrupted
+)
+
+// reapState is what the reaper remembers about a running session between
+// passes. Caller must hold m.mu to use it.
+type reapState struct {
+	outputSeq uint64 // last OutputBuffer sequence seen
+	screen    string // last screen seen, for sessions without an output tap
+	stage     reapStage
+	since     time.Time // when stage was entered
+	reason    string    // ReapIdle or ReapMaxLifetime, once warned
+}
+
+// RunReaper stops sessions that exceed idle_timeout or max_lifetime until
+// ctx is cancelled. A session is first warned (EventTimeoutWarning), one
+// timeout_grace later interrupted with Ctrl+C, and after another
+// timeout_grace killed (EventTimedOut, EventKilled). Output or input before
+// the interrupt resets the idle clock; after it, only input does, since
+// the interrupt itself prints.
+func (m *Manager) RunReaper(ctx context.Context) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.reap(now)
+		}
+	}
+}
+
+// reapCandidate is a running session with a limit, and its output source.
+type reapCandidate struct {
+	tmuxName string
+	buf      *OutputBuffer
+	screen   string
+	captured bool
+}
+
+// reap runs one reaper pass. Screens of sessions without an output tap
+// are captured, and interrupts and kills are sent, outside the lock.
+func (m *Manager) reap(now time.Time) {
+	m.mu.RLock()
+	candidates := make(map[string]*reapCandidate)
+	for id, s := range m.sessions {
+		if s.Status == StatusExited {
+			continue
+		}
+		if _, _, ok := m.reapDeadlineLocked(s); !ok {
+			continue
+		}
+		c := &reapCandidate{tmuxName: s.TmuxName}
+		if tap, ok := m.taps[id]; ok {
+			c.buf = tap.buf
+		}
+		candidates[id] = c
+	}
+	m.mu.RUnlock()
+
+	for _, c := range candidates {
+		if c.buf == nil {
+			screen, err := m.mux.CapturePane(c.tmuxName, false)
+			c.screen, c.captured = screen, err == nil
+		}
+	}
+
+	var interrupts, kills []string
+	m.mu.Lock()
+	for id := range m.reaps {
+		if _, ok := candidates[id]; !ok {
+			delete(m.reaps, id)
+		}
+	}
+	for id, c := range candidates {
+		s, ok := m.sessions[id]
+		if !ok || s.Status == StatusExited {
+			delete(m.reaps, id)
+			continue
+		}
+		st, seen := m.reaps[id]
+		if !seen {
+			st = &reapState{since: now}
+			m.reaps[id] = st
+		}
+		var output bool
+		if c.buf != nil {
+			seq := c.buf.LastSeq()
+			output, st.outputSeq = seq != st.outputSeq, seq
+		} else if c.captured {
+			output, st.screen = c.screen != st.screen, c.screen
+		}
+		if output && seen && st.stage < reapInterrupted {
+			m.touchLocked(s, now)
+		}
+
+		deadline, reason, ok := m.reapDeadlineLocked(s)
+		if !ok {
+			continue
+		}
+		grace := m.cfg.TimeoutGrace
+		switch st.stage {
+		case reapNone:
+			if !now.Before(deadline.Add(-grace)) {
+				st.stage, st.since, st.reason = reapWarned, now, reason
+				m.emitDetail(EventTimeoutWarning, s, reason)
+			}
+		case reapWarned:
+			if !now.Before(deadline) && !now.Before(st.since.Add(grace)) {
+				st.stage, st.since = reapInterrupted, now
+				interrupts = append(interrupts, c.tmuxName)
+			}
+		case reapInterrupted:
+			if !now.Before(st.since.Add(grace)) {
+				delete(m.reaps, id)
+				m.emitDetail(EventTimedOut, s, reason)
+				kills = append(kills, id)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	for _, name := range interrupts {
+		if err := m.mux.Interrupt(name); err != nil {
+			log.Printf("sessions: reaper: interrupt %q: %v", name, err)
+		}
+	}
+	for _, id := range kills {
+		if err := m.Kill(id); err != nil {
+			log.Printf("sessions: reaper: kill %q: %v", id, err)
+		}
+	}
+}
+
+// reapDeadlineLocked returns when a session reaches its idle timeout or
+// maximum lifetime, whichever comes first, and which one it is; ok is false
+// if neither applies. Caller must hold m.mu.
+func (m *Manager) reapDeadlineLocked(s *Session) (deadline time.Time, reason string, ok bool) {
+	idle, lifetime := m.cfg.IdleTimeout, m.cfg.MaxLifetime
+	if s.IdleTimeout != nil {
+		idle = time.Duration(*s.IdleTimeout)
+	}
+	if s.MaxLifetime != nil {
+		lifetime = time.Duration(*s.MaxLifetime)
+	}
+	if idle > 0 {
+		last := s.LastActivityAt
+		if last.IsZero() {
+			last = s.CreatedAt
+		}
+		deadline, reason, ok = last.Add(idle), ReapIdle, true
+	}
+	if lifetime > 0 {
+		if end := s.CreatedAt.Add(lifetime); !ok || end.Before(deadline) {
+			deadline, reason, ok = end, ReapMaxLifetime, true
+		}
+	}
+	return deadline, reason, ok
+}
+
+// touchLocked records activity in a session at now, which calls off a
+// pending idle timeout (but not a max_lifetime one). Caller must hold m.mu.
+func (m *Manager) touchLocked(s *Session, now time.Time) {
+	s.LastActivityAt = now
+	if st, ok := m.reaps[s.ID]; ok && st.reason != ReapMaxLifetime {
+		st.stage, st.since, st.reason = reapNone, now, ""
+	}
+}
@@ -0,0 +1,116 @@
+package sessions_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/cc-web/internal/config"
+	"github.com/user/cc-web/internal/sessions"
+	"github.com/user/cc-web/internal/sessions/sessionstest"
+)
+
+func TestReap_Idle(t *testing.T) {
+	fake := sessionstest.NewFakeMultiplexer()
+	m := sessions.NewManagerWithMultiplexer(&config.Config{
+		ProjectsAllowed: []string{"/"},
+		MaxSessions:     5,
+		SessionsFile:    filepath.Join(t.TempDir(), "sessions.json"),
+		IdleTimeout:     time.Hour,
+		TimeoutGrace:    time.Minute,
+	}, fake)
+	s, err := m.Create(sessions.CreateRequest{Name: "idle", CWD: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	off := sessions.Duration(0)
+	keep, err := m.Create(sessions.CreateRequest{Name: "keep", CWD: t.TempDir(), IdleTimeout: &off})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []sessions.Event
+	m.Subscribe(func(ev sessions.Event) {
+		if ev.SessionID == s.ID && ev.Type != sessions.EventInputSent {
+			events = append(events, ev)
+		}
+	})
+	start := s.LastActivityAt
+
+	m.Reap(start.Add(58 * time.Minute))
+	if len(events) != 0 {
+		t.Fatalf("events before the warning = %v", events)
+	}
+	m.Reap(start.Add(59 * time.Minute))
+	if len(events) != 1 || events[0].Type != sessions.EventTimeoutWarning || events[0].Detail != sessions.ReapIdle {
+		t.Fatalf("events = %+v", events)
+	}
+
+	// Input calls off the timeout
+	if err := m.SendText(s.ID, "", "still here"); err != nil {
+		t.Fatal(err)
+	}
+	resumed, _ := m.Get(s.ID)
+	m.Reap(resumed.LastActivityAt.Add(time.Minute))
+	if got := fake.Input(s.TmuxName); len(got) != 1 {
+		t.Errorf("input after resuming = %v", got)
+	}
+
+	// Warned, then interrupted, then killed, one grace period apart
+	resumed, _ = m.Get(s.ID)
+	start = resumed.LastActivityAt
+	m.Reap(start.Add(59 * time.Minute))
+	m.Reap(start.Add(60 * time.Minute))
+	if got := fake.Input(s.TmuxName); len(got) != 2 || got[1] != "C-c" {
+		t.Errorf("input after the deadline = %v", got)
+	}
+	// Output caused by the interrupt does not count as activity
+	fake.SetScreen(s.TmuxName, "^C\n")
+	m.Reap(start.Add(61 * time.Minute))
+	if _, ok := m.Get(s.ID); ok {
+		t.Fatal("idle session was not killed")
+	}
+	n := len(events)
+	if n < 3 || events[n-2].Type != sessions.EventTimedOut || events[n-1].Type != sessions.EventKilled {
+		t.Errorf("events = %+v", events)
+	}
+
+	if _, ok := m.Get(keep.ID); !ok {
+		t.Error("session with idle_timeout 0s was killed")
+	}
+}
+
+func TestReap_MaxLifetime(t *testing.T) {
+	fake := sessionstest.NewFakeMultiplexer()
+	m := sessions.NewManagerWithMultiplexer(&config.Config{
+		ProjectsAllowed: []string{"/"},
+		MaxSessions:     5,
+		SessionsFile:    filepath.Join(t.TempDir(), "sessions.json"),
+		TimeoutGrace:    time.Minute,
+	}, fake)
+	var lifetime sessions.Duration
+	if err := json.Unmarshal([]byte(`"2h"`), &lifetime); err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.Create(sessions.CreateRequest{Name: "short", CWD: t.TempDir(), MaxLifetime: &lifetime})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Reap(s.CreatedAt.Add(119 * time.Minute))
+	// Input does not extend a lifetime
+	if err := m.SendText(s.ID, "", "more"); err != nil {
+		t.Fatal(err)
+	}
+	m.Reap(s.CreatedAt.Add(120 * time.Minute))
+	m.Reap(s.CreatedAt.Add(121 * time.Minute))
+	if _, ok := m.Get(s.ID); ok {
+		t.Fatal("session outlived max_lifetime")
+	}
+
+	negative := sessions.Duration(-time.Second)
+	if _, err := m.Create(sessions.CreateRequest{Name: "bad", CWD: t.TempDir(), MaxLifetime: &negative}); err == nil {
+		t.Error("negative max_lifetime accepted")
+	}
+}
@@ -0,0 +1,178 @@
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// recordingNamePattern matches recording file names produced by startRecorder
+// (with milliseconds), and by earlier versions (without).
+var recordingNamePattern = regexp.MustCompile(`^[0-9]{8}T[0-9]{6}(\.[0-9]{3})?Z\.cast$`)
+
+// recordingNameLayout formats the start time into a recording file name.
+const recordingNameLayout = "20060102T150405.000Z"
+
+// maxRecordingNameTries bounds the names startRecorder tries when a
+// recording started in the same millisecond already exists.
+const maxRecordingNameTries = 100
+
+// Recording describes an asciicast file of a session.
+type Recording struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	StartedAt time.Time `json:"started_at"`
+	Active    bool      `json:"active"`
+}
+
+// castHeader is the first line of an asciicast v2 file.
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// recorder writes a session's output stream to an asciicast v2 file.
+// It stops by itself when the output buffer is closed (session killed,
+// exited, or gateway shutdown); a restarted gateway opens a new file.
+type recorder struct {
+	name string
+	f    *os.File
+	done chan struct{}
+}
+
+// startRecorder creates a new .cast file under dir and starts copying output
+// from buf into it. initial, if non-empty, is written as the first event so
+// playback starts from the screen that was visible when recording began.
+func startRecorder(dir, title string, buf *OutputBuffer, cols, rows int, initial string) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create recordings dir: %w", err)
+	}
+	now := time.Now()
+	// A tap restarted right away (Restart of a running session) starts a new
+	// recording; later names are taken a millisecond on, so they still sort
+	// by start time.
+	var name string
+	var f *os.File
+	var err error
+	for i := 0; i < maxRecordingNameTries; i++ {
+		name = now.Add(time.Duration(i)*time.Millisecond).UTC().Format(recordingNameLayout) + ".cast"
+		f, err = os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create recording: %w", err)
+	}
+
+	hdr, _ := json.Marshal(castHeader{
+		Version:   2,
+		Width:     cols,
+		Height:    rows,
+		Timestamp: now.Unix(),
+		Title:     title,
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	})
+	if _, err := f.Write(append(hdr, '\n')); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write recording header: %w", err)
+	}
+
+	r := &recorder{name: name, f: f, done: make(chan struct{})}
+	after := buf.LastSeq()
+	if initial != "" {
+		r.writeEvent(0, "o", "\x1b[H\x1b[2J"+strings.ReplaceAll(strings.TrimRight(initial, "\n"), "\n", "\r\n"))
+	}
+	go r.run(buf, after, now)
+	return r, nil
+}
+
+func (r *recorder) run(buf *OutputBuffer, after uint64, start time.Time) {
+	defer close(r.done)
+	defer r.f.Close()
+	for {
+		chunks, complete, wait := buf.Since(after)
+		if !complete {
+			// The recorder fell behind and output was evicted before it was
+			// written; mark the gap so playback does not hide it.
+			if err := r.writeEvent(time.Since(start).Seconds(), "m", gapMarker); err != nil {
+				log.Printf("sessions: recording %s: %v", r.name, err)
+				return
+			}
+		}
+		for _, c := range chunks {
+			if err := r.writeEvent(time.Since(start).Seconds(), "o", string(c.Data)); err != nil {
+				log.Printf("sessions: recording %s: %v", r.name, err)
+				return
+			}
+			after = c.Seq
+		}
+		if len(chunks) == 0 && buf.Closed() {
+			return
+		}
+		<-wait
+	}
+}
+
+// gapMarker labels the marker event written where output was lost.
+const gapMarker = "output lost"
+
+// writeEvent appends one event line: [elapsed, code, data], where code is
+// "o" for output or "m" for a marker.
+func (r *recorder) writeEvent(elapsed float64, code, data string) error {
+	line, err := json.Marshal([]interface{}{math.Round(elapsed*1e6) / 1e6, code, data})
+	if err != nil {
+		return err
+	}
+	_, err = r.f.Write(append(line, '\n'))
+	return err
+}
+
+// active reports whether the recorder is still writing.
+func (r *recorder) active() bool {
+	select {
+	case <-r.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// listRecordings returns the recordings in dir, oldest first.
+func listRecordings(dir string) ([]Recording, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Recording{}, nil
+		}
+		return nil, fmt.Errorf("read recordings dir: %w", err)
+	}
+	result := []Recording{}
+	for _, e := range entries {
+		if e.IsDir() || !recordingNamePattern.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		// The layout without milliseconds parses both forms
+		started, _ := time.Parse("20060102T150405Z", strings.TrimSuffix(e.Name(), ".cast"))
+		result = append(result, Recording{Name: e.Name(), Size: info.Size(), StartedAt: started})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
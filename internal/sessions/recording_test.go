@@ -0,0 +1,129 @@
+package sessions
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorderWritesAsciicast(t *testing.T) {
+	dir := t.TempDir()
+	buf := NewOutputBuffer(1024)
+
+	rec, err := startRecorder(dir, "demo", buf, 100, 30, "line1\nline2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Append([]byte("hello"))
+	buf.Close()
+	select {
+	case <-rec.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("recorder did not stop after buffer closed")
+	}
+
+	f, err := os.Open(filepath.Join(dir, rec.name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+
+	sc.Scan()
+	var hdr castHeader
+	if err := json.Unmarshal(sc.Bytes(), &hdr); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	if hdr.Version != 2 || hdr.Width != 100 || hdr.Height != 30 || hdr.Title != "demo" {
+		t.Errorf("header = %+v", hdr)
+	}
+
+	var events [][]interface{}
+	for sc.Scan() {
+		var ev []interface{}
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("event %q: %v", sc.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[0][2] != "\x1b[H\x1b[2Jline1\r\nline2" {
+		t.Errorf("initial event = %q", events[0][2])
+	}
+	if events[1][1] != "o" || events[1][2] != "hello" {
+		t.Errorf("output event = %v", events[1])
+	}
+
+	list, err := listRecordings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != rec.name || list[0].Size == 0 {
+		t.Errorf("listRecordings = %+v", list)
+	}
+}
+
+func TestRecorderMarksGap(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "gap.cast"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := NewOutputBuffer(4)
+	buf.Append([]byte("lost"))
+	buf.Append([]byte("kept"))
+	buf.Close()
+
+	rec := &recorder{name: "gap.cast", f: f, done: make(chan struct{})}
+	rec.run(buf, 0, time.Now())
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events [][]interface{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		var ev []interface{}
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("event %q: %v", sc.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) != 2 || events[0][1] != "m" || events[0][2] != gapMarker || events[1][2] != "kept" {
+		t.Errorf("events = %v", events)
+	}
+}
+
+func TestRecorderNamesDoNotCollide(t *testing.T) {
+	dir := t.TempDir()
+	var names []string
+	for i := 0; i < 3; i++ {
+		buf := NewOutputBuffer(1024)
+		rec, err := startRecorder(dir, "demo", buf, 80, 24, "")
+		if err != nil {
+			t.Fatalf("recording %d: %v", i, err)
+		}
+		buf.Close()
+		<-rec.done
+		names = append(names, rec.name)
+	}
+
+	list, err := listRecordings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("listRecordings = %+v", list)
+	}
+	for i, r := range list {
+		if r.Name != names[i] || r.StartedAt.IsZero() {
+			t.Errorf("recording %d = %+v, want %s", i, r, names[i])
+		}
+	}
+}
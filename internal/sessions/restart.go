@@ -0,0 +1,127 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// ErrSessionRunning is returned by Restart for a session that is running
+// and has no dead panes to respawn.
+var ErrSessionRunning = errors.New("session is running")
+
+// Restart brings a session back under the same ID. An exited session is
+// respawned in place if the multiplexer still has it, or else started again
+// with its recorded name, cwd, start command and env; a running one has its
+// dead panes respawned. The embedded terminal and output tap are restarted
+// with it.
+func (m *Manager) Restart(id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, &notFoundError{id: id}
+	}
+
+	if s.Status != StatusExited {
+		respawner, ok := m.mux.(Respawner)
+		if !ok {
+			return nil, ErrSessionRunning
+		}
+		n, err := respawner.RespawnDeadPanes(s.TmuxName)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, ErrSessionRunning
+		}
+		// The respawned pane no longer pipes into the old tap
+		m.stopTapLocked(id)
+		m.startTapLocked(id, s.TmuxName)
+		s.LastSeenAt = time.Now()
+		m.emit(EventRestarted, s, string(s.Status))
+		cp := *s
+		return &cp, nil
+	}
+
+	// The project may have moved or been dropped from the allowlist since
+	if !m.cfg.IsPathAllowed(s.CWD) {
+		return nil, fmt.Errorf("path %q is not in allowed list", s.CWD)
+	}
+	if info, err := os.Stat(s.CWD); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("path %q does not exist or is not a directory", s.CWD)
+	}
+	activeCount := 0
+	for _, other := range m.sessions {
+		if other.Status != StatusExited {
+			activeCount++
+		}
+	}
+	if activeCount >= m.cfg.MaxSessions {
+		return nil, fmt.Errorf("max active sessions (%d) reached", m.cfg.MaxSessions)
+	}
+
+	// A dead session that could not be killed is respawned in place
+	respawned := false
+	if respawner, ok := m.mux.(Respawner); ok {
+		n, err := respawner.RespawnDeadPanes(s.TmuxName)
+		respawned = err == nil && n > 0
+	}
+	if !respawned {
+		if err := m.mux.CreateSession(s.TmuxName, s.CWD, s.StartCmd, s.Env); err != nil {
+			return nil, fmt.Errorf("create tmux session: %w", err)
+		}
+	}
+
+	prev := s.Status
+	s.ExitCode, s.ExitedAt, s.FinalScreen = nil, nil, ""
+	s.Status = StatusRunning
+	s.AgentState = AgentUnknown
+	s.LastSeenAt = time.Now()
+	s.LastActivityAt = s.LastSeenAt
+	if cols, rows, err := m.mux.WindowSize(s.TmuxName); err == nil {
+		s.Cols, s.Rows = cols, rows
+	}
+	m.restartTerminalLocked(s)
+	m.startTapLocked(id, s.TmuxName)
+	m.saveToFile()
+	m.emit(EventStatusChanged, s, string(prev))
+	m.emit(EventRestarted, s, string(prev))
+	cp := *s
+	return &cp, nil
+}
+
+// restartTerminalLocked brings up the embedded terminal of a restarted
+// session: ttyd on a newly allocated port, since the old one may have gone
+// to another session meanwhile, or the built-in terminal. A failure leaves
+// the session without one. Caller must hold m.mu.
+func (m *Manager) restartTerminalLocked(s *Session) {
+	if m.builtinTerminal() {
+		s.TtydPort = 0
+		s.TerminalURL = fmt.Sprintf("/t/%s/", s.ID)
+		return
+	}
+	if !m.ttydAvailable() {
+		return
+	}
+	port, err := m.ttyd.AllocatePort()
+	if err != nil {
+		log.Printf("sessions: restart %q: allocate port: %v", s.TmuxName, err)
+		s.TtydPort = 0
+		s.TerminalURL = ""
+		return
+	}
+	if err := m.startTtyd(s.TmuxName, port); err != nil {
+		log.Printf("sessions: restart %q: start ttyd: %v", s.TmuxName, err)
+		m.ttyd.ReleasePort(port)
+		s.TtydPort = 0
+		s.TerminalURL = ""
+		return
+	}
+	s.TtydPort = port
+	s.TerminalURL = fmt.Sprintf("/t/%s/", s.ID)
+	m.emit(EventTtydRestarted, s, "")
+}
@@ -0,0 +1,324 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// screenCols and screenRows are the size of a detached GNU screen session;
+// screen has no command to query it from outside.
+const (
+	screenCols = 80
+	screenRows = 24
+)
+
+// screenKeys maps tmux key names (as produced by ParseKeys) to the bytes an
+// xterm sends, since screen's stuff command only takes raw input.
+var screenKeys = map[string]string{
+	"Escape": "\x1b",
+	"Enter":  "\r",
+	"Tab":    "\t",
+	"BTab":   "\x1b[Z",
+	"BSpace": "\x7f",
+	"Space":  " ",
+	"Up":     "\x1b[A",
+	"Down":   "\x1b[B",
+	"Right":  "\x1b[C",
+	"Left":   "\x1b[D",
+	"Home":   "\x1b[1~",
+	"End":    "\x1b[4~",
+	"IC":     "\x1b[2~",
+	"DC":     "\x1b[3~",
+	"PPage":  "\x1b[5~",
+	"NPage":  "\x1b[6~",
+	"F1":     "\x1bOP",
+	"F2":     "\x1bOQ",
+	"F3":     "\x1bOR",
+	"F4":     "\x1bOS",
+	"F5":     "\x1b[15~",
+	"F6":     "\x1b[17~",
+	"F7":     "\x1b[18~",
+	"F8":     "\x1b[19~",
+	"F9":     "\x1b[20~",
+	"F10":    "\x1b[21~",
+	"F11":    "\x1b[23~",
+	"F12":    "\x1b[24~",
+}
+
+// ScreenRunner hosts sessions in GNU screen, for hosts where tmux is not
+// allowed. Screen captures are hardcopies, so they carry no ANSI
+// attributes, and output streaming uses screen's session log.
+type ScreenRunner struct{}
+
+func NewScreenRunner() *ScreenRunner {
+	return &ScreenRunner{}
+}
+
+func (s *ScreenRunner) run(args ...string) (string, error) {
+	out, err := exec.Command("screen", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", string(out), err)
+	}
+	return string(out), nil
+}
+
+// stuff types data into the session's first window.
+func (s *ScreenRunner) stuff(name, data string) error {
+	_, err := s.run("-S", name, "-p", "0", "-X", "stuff", screenEscape(data))
+	return err
+}
+
+// CreateSession starts a detached screen session.
+func (s *ScreenRunner) CreateSession(name, cwd, startCmd string, env map[string]string) error {
+	args := []string{"-dmS", name}
+	if startCmd != "" {
+		args = append(args, "sh", "-c", startCmd)
+	}
+	cmd := exec.Command("screen", args...)
+	cmd.Dir = cwd
+	cmd.Env = os.Environ()
+	for name, value := range env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("screen -dmS: %s: %w", string(out), err)
+	}
+	return nil
+}
+
+// KillSession ends the screen session.
+func (s *ScreenRunner) KillSession(name string) error {
+	if _, err := s.run("-S", name, "-X", "quit"); err != nil {
+		return fmt.Errorf("screen quit: %w", err)
+	}
+	return nil
+}
+
+// SendKeys types text followed by Enter.
+func (s *ScreenRunner) SendKeys(name, text string) error {
+	if err := s.stuff(name, text+"\r"); err != nil {
+		return fmt.Errorf("screen stuff: %w", err)
+	}
+	return nil
+}
+
+// SendRawKeys translates tmux key names to terminal input and types it.
+func (s *ScreenRunner) SendRawKeys(name string, keys []string) error {
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(screenKeySequence(k))
+	}
+	if err := s.stuff(name, b.String()); err != nil {
+		return fmt.Errorf("screen stuff: %w", err)
+	}
+	return nil
+}
+
+// Interrupt sends Ctrl+C.
+func (s *ScreenRunner) Interrupt(name string) error {
+	if err := s.stuff(name, "\x03"); err != nil {
+		return fmt.Errorf("screen interrupt: %w", err)
+	}
+	return nil
+}
+
+// ListSessions returns the names of all screen sessions of this user.
+func (s *ScreenRunner) ListSessions() ([]string, error) {
+	// screen -ls exits non-zero even when it lists sessions; go by the output
+	out, err := exec.Command("screen", "-ls").CombinedOutput()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("screen -ls: %w", err)
+	}
+	return parseScreenList(string(out))
+}
+
+// CapturePane returns the visible screen. escapes is ignored: hardcopies
+// are plain text.
+func (s *ScreenRunner) CapturePane(name string, escapes bool) (string, error) {
+	lines, err := s.hardcopy(name, false)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// PaneSize returns the number of scrollback lines and the screen height.
+func (s *ScreenRunner) PaneSize(name string) (history, height int, err error) {
+	lines, err := s.hardcopy(name, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	height = min(screenRows, len(lines))
+	return len(lines) - height, height, nil
+}
+
+// CaptureRange returns scrollback lines using tmux line numbering.
+func (s *ScreenRunner) CaptureRange(name string, start, end int) ([]string, error) {
+	lines, err := s.hardcopy(name, true)
+	if err != nil {
+		return nil, err
+	}
+	history := len(lines) - min(screenRows, len(lines))
+	from := max(start+history, 0)
+	to := min(end+history, len(lines)-1)
+	if from > to {
+		return nil, nil
+	}
+	return lines[from : to+1], nil
+}
+
+// WindowSize returns the fixed size of detached screen sessions.
+func (s *ScreenRunner) WindowSize(name string) (cols, rows int, err error) {
+	return screenCols, screenRows, nil
+}
+
+// PipeOutput turns on the session log, written to path without buffering.
+func (s *ScreenRunner) PipeOutput(name, path string) error {
+	for _, cmd := range [][]string{
+		{"logfile", path},
+		{"logfile", "flush", "0"},
+		{"log", "on"},
+	} {
+		args := append([]string{"-S", name, "-p", "0", "-X"}, cmd...)
+		if _, err := s.run(args...); err != nil {
+			return fmt.Errorf("screen %s: %w", cmd[0], err)
+		}
+	}
+	return nil
+}
+
+// AttachCommand attaches without detaching other displays, like tmux does.
+func (s *ScreenRunner) AttachCommand(name string) []string {
+	return []string{"screen", "-x", name}
+}
+
+// hardcopy dumps the screen (and with history, the scrollback before it)
+// to a temporary file and returns its lines. screen writes the file
+// asynchronously after -X returns, so wait for it to appear and settle.
+func (s *ScreenRunner) hardcopy(name string, history bool) ([]string, error) {
+	dir, err := os.MkdirTemp("", "cc-web-hardcopy-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "screen.txt")
+
+	args := []string{"-S", name, "-p", "0", "-X", "hardcopy"}
+	if history {
+		args = append(args, "-h")
+	}
+	if _, err := s.run(append(args, path)...); err != nil {
+		return nil, fmt.Errorf("screen hardcopy: %w", err)
+	}
+
+	lastSize := int64(-1)
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.Size() == lastSize {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+		}
+		lastSize = info.Size()
+	}
+	return nil, fmt.Errorf("screen hardcopy: %s: no output", name)
+}
+
+// screenEscape protects the characters screen interprets in command
+// arguments: backslash escapes, ^X control notation and $VAR expansion.
+func screenEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `^`, `\^`, `$`, `\$`)
+	return r.Replace(s)
+}
+
+// screenKeySequence returns the input bytes for a tmux key name: named keys
+// from screenKeys, raw "0xNN" bytes, C-x and M-x combinations, C- and S-
+// named keys in xterm's modified form, anything else literally.
+func screenKeySequence(key string) string {
+	if seq, ok := screenKeys[key]; ok {
+		return seq
+	}
+	if isHexKey(key) {
+		if b, err := strconv.ParseUint(key[2:], 16, 8); err == nil {
+			return string([]byte{byte(b)})
+		}
+	}
+	if rest, ok := strings.CutPrefix(key, "M-"); ok && rest != "" {
+		return "\x1b" + screenKeySequence(rest)
+	}
+	if rest, ok := strings.CutPrefix(key, "C-"); ok && len(rest) == 1 {
+		c := rest[0]
+		if c >= 'a' && c <= 'z' || c >= '@' && c <= '_' {
+			return string(rune(c & 0x1f))
+		}
+	}
+	if seq, ok := xtermModifiedKey(key); ok {
+		return seq
+	}
+	return key
+}
+
+// xtermModifiedKey returns the xterm sequence for a named key with C- and
+// S- prefixes, e.g. C-Left is ESC [1;5D.
+func xtermModifiedKey(key string) (string, bool) {
+	mod := 1
+	for {
+		if rest, ok := strings.CutPrefix(key, "S-"); ok {
+			mod, key = mod+1, rest
+		} else if rest, ok := strings.CutPrefix(key, "C-"); ok {
+			mod, key = mod+4, rest
+		} else {
+			break
+		}
+	}
+	seq, ok := screenKeys[key]
+	if !ok || mod == 1 || len(seq) < 3 || seq[0] != '\x1b' {
+		return "", false
+	}
+	final := seq[len(seq)-1]
+	if final == '~' {
+		return fmt.Sprintf("%s;%d~", seq[:len(seq)-1], mod), true
+	}
+	// ESC [ A and ESC O P forms
+	return fmt.Sprintf("\x1b[1;%d%c", mod, final), true
+}
+
+// parseScreenList extracts session names from `screen -ls` output, where
+// each session is listed as "<tab><pid>.<name><tab>(<state>)".
+func parseScreenList(out string) ([]string, error) {
+	if strings.Contains(out, "No Sockets found") {
+		return nil, nil
+	}
+	// A listing ends with "N Socket(s) in <dir>."; anything else is an error
+	// message, which must not be taken for "no sessions".
+	if !strings.Contains(out, "Socket in ") && !strings.Contains(out, "Sockets in ") {
+		return nil, fmt.Errorf("screen -ls: unexpected output: %s", strings.TrimSpace(out))
+	}
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		// Dead sessions are only left for screen -wipe
+		if !strings.HasPrefix(line, "\t") || strings.Contains(line, "(Dead") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if _, name, ok := strings.Cut(fields[0], "."); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
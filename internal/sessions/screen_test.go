@@ -0,0 +1,54 @@
+package sessions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseScreenList(t *testing.T) {
+	out := "There are screens on:\n" +
+		"\t12345.claude-demo\t(10/16/2026 09:00:00 AM)\t(Detached)\n" +
+		"\t678.other.name\t(Attached)\n" +
+		"\t999.claude-gone\t(Dead ???)\n" +
+		"3 Sockets in /run/screen/S-user.\n"
+	got, err := parseScreenList(out)
+	want := []string{"claude-demo", "other.name"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseScreenList = %v, %v; want %v", got, err, want)
+	}
+	if got, err := parseScreenList("No Sockets found in /run/screen/S-user.\n"); err != nil || len(got) != 0 {
+		t.Errorf("parseScreenList(empty) = %v, %v", got, err)
+	}
+	if _, err := parseScreenList("Cannot make directory '/run/screen': Permission denied\n"); err == nil {
+		t.Error("parseScreenList accepted an error message")
+	}
+}
+
+func TestScreenEscape(t *testing.T) {
+	if got := screenEscape(`a\b ^C $HOME`); got != `a\\b \^C \$HOME` {
+		t.Errorf("screenEscape = %q", got)
+	}
+}
+
+func TestScreenKeySequence(t *testing.T) {
+	tests := map[string]string{
+		"Escape": "\x1b",
+		"Up":     "\x1b[A",
+		"C-c":    "\x03",
+		"C-l":    "\x0c",
+		"M-x":    "\x1bx",
+		"M-Up":   "\x1b\x1b[A",
+		"F5":     "\x1b[15~",
+		"abc":    "abc",
+		"0x1b":   "\x1b",
+		"C-Left": "\x1b[1;5D",
+		"S-Up":   "\x1b[1;2A",
+		"C-DC":   "\x1b[3;5~",
+		"S-F1":   "\x1b[1;2P",
+	}
+	for key, want := range tests {
+		if got := screenKeySequence(key); got != want {
+			t.Errorf("screenKeySequence(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
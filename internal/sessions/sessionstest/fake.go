@@ -0,0 +1,251 @@
+// Package sessionstest provides an in-memory multiplexer backend for
+// testing code built on the sessions package.
+package sessionstest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/user/cc-web/internal/sessions"
+)
+
+// FakeMultiplexer is an in-memory sessions.Multiplexer for tests. Sessions exist
+// only in the map; text sent to a session is appended to its screen, as if
+// it ran cat, and every input is recorded for inspection.
+type FakeMultiplexer struct {
+	mu       sync.Mutex
+	sessions map[string]*fakeSession
+}
+
+// fakeCols and fakeRows are the initial screen size of fake sessions.
+const (
+	fakeCols = 80
+	fakeRows = 24
+)
+
+var (
+	_ sessions.Multiplexer  = (*FakeMultiplexer)(nil)
+	_ sessions.Paster       = (*FakeMultiplexer)(nil)
+	_ sessions.Resizer      = (*FakeMultiplexer)(nil)
+	_ sessions.ExitReporter = (*FakeMultiplexer)(nil)
+	_ sessions.Respawner    = (*FakeMultiplexer)(nil)
+)
+
+type fakeSession struct {
+	cwd      string
+	startCmd string
+	env      map[string]string
+	dead     bool
+	status   int
+	screen   []string
+	input    []string
+	cols     int
+	rows     int
+}
+
+func NewFakeMultiplexer() *FakeMultiplexer {
+	return &FakeMultiplexer{sessions: make(map[string]*fakeSession)}
+}
+
+func (f *FakeMultiplexer) get(name string) (*fakeSession, error) {
+	s, ok := f.sessions[name]
+	if !ok {
+		return nil, fmt.Errorf("fake: can't find session: %s", name)
+	}
+	return s, nil
+}
+
+func (f *FakeMultiplexer) CreateSession(name, cwd, startCmd string, env map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.sessions[name]; ok {
+		return fmt.Errorf("fake: duplicate session: %s", name)
+	}
+	f.sessions[name] = &fakeSession{cwd: cwd, startCmd: startCmd, env: env, cols: fakeCols, rows: fakeRows}
+	return nil
+}
+
+func (f *FakeMultiplexer) KillSession(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.get(name); err != nil {
+		return err
+	}
+	delete(f.sessions, name)
+	return nil
+}
+
+func (f *FakeMultiplexer) SendKeys(name, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.get(name)
+	if err != nil {
+		return err
+	}
+	s.input = append(s.input, text)
+	s.screen = append(s.screen, strings.Split(text, "\n")...)
+	return nil
+}
+
+func (f *FakeMultiplexer) Paste(name, text string, enter bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.get(name)
+	if err != nil {
+		return err
+	}
+	s.input = append(s.input, text)
+	if enter {
+		s.input = append(s.input, "Enter")
+	}
+	s.screen = append(s.screen, strings.Split(text, "\n")...)
+	return nil
+}
+
+func (f *FakeMultiplexer) SendRawKeys(name string, keys []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.get(name)
+	if err != nil {
+		return err
+	}
+	s.input = append(s.input, keys...)
+	return nil
+}
+
+func (f *FakeMultiplexer) Interrupt(name string) error {
+	return f.SendRawKeys(name, []string{"C-c"})
+}
+
+func (f *FakeMultiplexer) ListSessions() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, 0, len(f.sessions))
+	for name := range f.sessions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (f *FakeMultiplexer) CapturePane(name string, escapes bool) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.get(name)
+	if err != nil {
+		return "", err
+	}
+	visible := s.screen[max(len(s.screen)-s.rows, 0):]
+	return strings.Join(visible, "\n") + "\n", nil
+}
+
+func (f *FakeMultiplexer) PaneSize(name string) (history, height int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.get(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	height = min(len(s.screen), s.rows)
+	return len(s.screen) - height, height, nil
+}
+
+func (f *FakeMultiplexer) CaptureRange(name string, start, end int) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.get(name)
+	if err != nil {
+		return nil, err
+	}
+	history := len(s.screen) - min(len(s.screen), s.rows)
+	from := max(start+history, 0)
+	to := min(end+history, len(s.screen)-1)
+	if from > to {
+		return nil, nil
+	}
+	return append([]string(nil), s.screen[from:to+1]...), nil
+}
+
+func (f *FakeMultiplexer) WindowSize(name string) (cols, rows int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.get(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	return s.cols, s.rows, nil
+}
+
+func (f *FakeMultiplexer) ResizeWindow(name string, cols, rows int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.get(name)
+	if err != nil {
+		return err
+	}
+	s.cols, s.rows = cols, rows
+	return nil
+}
+
+// SetScreen replaces the contents of a session's screen.
+func (f *FakeMultiplexer) SetScreen(name, screen string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.sessions[name]; ok {
+		s.screen = strings.Split(strings.TrimSuffix(screen, "\n"), "\n")
+	}
+}
+
+// Input returns everything sent to a session: texts from SendKeys and
+// key tokens from SendRawKeys and Interrupt, in order.
+func (f *FakeMultiplexer) Input(name string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.sessions[name]; ok {
+		return append([]string(nil), s.input...)
+	}
+	return nil
+}
+
+// Exit ends a session as if its process had quit.
+func (f *FakeMultiplexer) Exit(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.sessions, name)
+}
+
+// Finish ends a session's process with the given status but keeps the
+// session and its screen, like tmux remain-on-exit.
+func (f *FakeMultiplexer) Finish(name string, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.sessions[name]; ok {
+		s.dead, s.status = true, status
+	}
+}
+
+func (f *FakeMultiplexer) ExitedSessions() (map[string]*int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	exited := map[string]*int{}
+	for name, s := range f.sessions {
+		if s.dead {
+			status := s.status
+			exited[name] = &status
+		}
+	}
+	return exited, nil
+}
+
+func (f *FakeMultiplexer) RespawnDeadPanes(name string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, err := f.get(name)
+	if err != nil || !s.dead {
+		return 0, err
+	}
+	s.dead = false
+	return 1, nil
+}
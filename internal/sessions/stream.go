@@ -0,0 +1,127 @@
+package sessions
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// OutputChunk is a piece of pane output with its position in the stream.
+type OutputChunk struct {
+	Seq  uint64
+	Data []byte
+}
+
+// OutputBuffer is a bounded ring of recent pane output. Sequence numbers
+// start at 1 and increase by one per chunk, so a reader can resume after the
+// last chunk it saw as long as that chunk has not been evicted yet.
+type OutputBuffer struct {
+	mu       sync.Mutex
+	chunks   []OutputChunk
+	size     int
+	maxBytes int
+	lastSeq  uint64
+	notify   chan struct{}
+	closed   bool
+}
+
+func NewOutputBuffer(maxBytes int) *OutputBuffer {
+	return &OutputBuffer{
+		maxBytes: maxBytes,
+		notify:   make(chan struct{}),
+	}
+}
+
+// Append stores a copy of data as the next chunk and wakes waiting readers.
+func (b *OutputBuffer) Append(data []byte) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || len(data) == 0 {
+		return b.lastSeq
+	}
+
+	b.lastSeq++
+	b.chunks = append(b.chunks, OutputChunk{Seq: b.lastSeq, Data: append([]byte(nil), data...)})
+	b.size += len(data)
+	// Evict oldest chunks, but always keep the newest one
+	for b.size > b.maxBytes && len(b.chunks) > 1 {
+		b.size -= len(b.chunks[0].Data)
+		b.chunks = b.chunks[1:]
+	}
+
+	close(b.notify)
+	b.notify = make(chan struct{})
+	return b.lastSeq
+}
+
+// LastSeq returns the sequence number of the newest chunk (0 if none yet).
+func (b *OutputBuffer) LastSeq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastSeq
+}
+
+// Since returns all retained chunks with Seq > after. complete is false when
+// chunks following after have already been evicted, i.e. the reader missed
+// output. wait is closed when new output arrives or the buffer is closed.
+func (b *OutputBuffer) Since(after uint64) (chunks []OutputChunk, complete bool, wait <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if after > b.lastSeq {
+		// Sequence from a previous buffer (e.g. before a gateway restart)
+		after = b.lastSeq
+		complete = false
+	}
+	if len(b.chunks) > 0 && after+1 < b.chunks[0].Seq {
+		complete = false
+	}
+	for _, c := range b.chunks {
+		if c.Seq > after {
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks, complete, b.notify
+}
+
+// Closed reports whether the buffer stopped receiving output.
+func (b *OutputBuffer) Closed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// Close stops the buffer and wakes all waiting readers.
+func (b *OutputBuffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	close(b.notify)
+}
+
+// incompleteUTF8Suffix returns the length of a truncated multi-byte
+// sequence at the end of p (0 if p ends on a rune boundary).
+func incompleteUTF8Suffix(p []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(p); i++ {
+		c := p[len(p)-i]
+		if c < 0x80 {
+			return 0
+		}
+		if utf8.RuneStart(c) {
+			if utf8.FullRune(p[len(p)-i:]) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
+
+// shellQuote wraps s in single quotes for use in a /bin/sh command line.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
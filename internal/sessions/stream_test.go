@@ -0,0 +1,70 @@
+package sessions
+
+import "testing"
+
+func TestOutputBufferSince(t *testing.T) {
+	b := NewOutputBuffer(8)
+	b.Append([]byte("abc"))
+	b.Append([]byte("def"))
+
+	chunks, complete, _ := b.Since(1)
+	if !complete || len(chunks) != 1 || string(chunks[0].Data) != "def" || chunks[0].Seq != 2 {
+		t.Errorf("Since(1) = %v, %v; want [def] complete", chunks, complete)
+	}
+
+	// Third chunk pushes the buffer over 8 bytes and evicts seq 1
+	b.Append([]byte("ghi"))
+	if _, complete, _ := b.Since(0); complete {
+		t.Error("Since(0) after eviction should report a gap")
+	}
+	chunks, complete, _ = b.Since(1)
+	if !complete || len(chunks) != 2 {
+		t.Errorf("Since(1) = %d chunks, complete=%v; want 2, true", len(chunks), complete)
+	}
+	if _, complete, _ := b.Since(99); complete {
+		t.Error("Since beyond last seq should report a gap")
+	}
+}
+
+func TestOutputBufferWake(t *testing.T) {
+	b := NewOutputBuffer(1024)
+	_, _, wait := b.Since(0)
+	b.Append([]byte("x"))
+	select {
+	case <-wait:
+	default:
+		t.Fatal("Append did not wake waiters")
+	}
+
+	_, _, wait = b.Since(1)
+	b.Close()
+	select {
+	case <-wait:
+	default:
+		t.Fatal("Close did not wake waiters")
+	}
+	if !b.Closed() {
+		t.Error("Closed() = false after Close")
+	}
+}
+
+func TestIncompleteUTF8Suffix(t *testing.T) {
+	tests := []struct {
+		input    []byte
+		expected int
+	}{
+		{[]byte("abc"), 0},
+		{[]byte("é"), 0},
+		{[]byte("a\xc3"), 1},
+		{[]byte("a\xe2\x82"), 2},
+		{[]byte("a\xe2\x82\xac"), 0},
+		{[]byte("\xf0\x9f\x98"), 3},
+	}
+
+	for _, tt := range tests {
+		got := incompleteUTF8Suffix(tt.input)
+		if got != tt.expected {
+			t.Errorf("incompleteUTF8Suffix(%q) = %d, want %d", tt.input, got, tt.expected)
+		}
+	}
+}
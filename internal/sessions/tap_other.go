@@ -0,0 +1,18 @@
+//go:build !unix
+
+package sessions
+
+import "errors"
+
+var errNoFIFO = errors.New("output taps are not supported on this platform")
+
+// outputTap is unavailable without FIFOs; sessions run without one.
+type outputTap struct {
+	buf *OutputBuffer
+}
+
+func startTap(piper OutputPiper, dir, tmuxName string, maxBytes int) (*outputTap, error) {
+	return nil, errNoFIFO
+}
+
+func (t *outputTap) stop() {}
@@ -0,0 +1,76 @@
+//go:build unix
+
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// outputTap feeds a session's pane output into an OutputBuffer.
+// The multiplexer writes into a FIFO owned by the gateway; a goroutine reads it.
+type outputTap struct {
+	fifo string
+	f    *os.File
+	buf  *OutputBuffer
+}
+
+// startTap creates a FIFO, points the session's output at it (tmux
+// pipe-pane, screen's log) and starts reading.
+func startTap(piper OutputPiper, dir, tmuxName string, maxBytes int) (*outputTap, error) {
+	fifo := filepath.Join(dir, tmuxName+".fifo")
+	_ = os.Remove(fifo)
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		return nil, fmt.Errorf("mkfifo: %w", err)
+	}
+	// O_RDWR keeps the FIFO open when the writer goes away and avoids
+	// blocking until tmux attaches its writer.
+	f, err := os.OpenFile(fifo, os.O_RDWR, 0)
+	if err != nil {
+		_ = os.Remove(fifo)
+		return nil, fmt.Errorf("open fifo: %w", err)
+	}
+	if err := piper.PipeOutput(tmuxName, fifo); err != nil {
+		f.Close()
+		_ = os.Remove(fifo)
+		return nil, err
+	}
+
+	tap := &outputTap{fifo: fifo, f: f, buf: NewOutputBuffer(maxBytes)}
+	go tap.run(tmuxName)
+	return tap, nil
+}
+
+func (t *outputTap) run(tmuxName string) {
+	defer t.buf.Close()
+	buf := make([]byte, 32*1024)
+	var pending []byte
+	for {
+		n, err := t.f.Read(buf)
+		if n > 0 {
+			data := append(pending, buf[:n]...)
+			// Hold back a trailing partial UTF-8 sequence so chunks stay valid text
+			cut := incompleteUTF8Suffix(data)
+			t.buf.Append(data[:len(data)-cut])
+			pending = append([]byte(nil), data[len(data)-cut:]...)
+		}
+		if err != nil {
+			if !t.buf.Closed() && !errors.Is(err, os.ErrClosed) {
+				log.Printf("sessions: output tap %q: %v", tmuxName, err)
+			}
+			return
+		}
+	}
+}
+
+// stop closes the reader, which ends run, and removes the FIFO.
+// tmux's writer gets SIGPIPE on its next write and exits on its own.
+func (t *outputTap) stop() {
+	t.buf.Close()
+	t.f.Close()
+	_ = os.Remove(t.fifo)
+}
@@ -0,0 +1,95 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrUnknownTemplate is returned by Create for a template that is not
+// defined in the config.
+var ErrUnknownTemplate = errors.New("unknown template")
+
+// Waiting for a new session to be ready for its initial prompt.
+const (
+	promptPollInterval = 500 * time.Millisecond
+	// promptSettle is how long an unchanged screen means the program waits
+	// for input, when the agent state rules do not recognise it.
+	promptSettle  = 2 * time.Second
+	promptTimeout = 30 * time.Second
+)
+
+// applyTemplate fills the fields req leaves empty from the template it
+// names, and returns the template's env separately since, unlike
+// req.Env, it is not filtered.
+func (m *Manager) applyTemplate(req CreateRequest) (CreateRequest, map[string]string, error) {
+	if req.Template == "" {
+		return req, nil, nil
+	}
+	tpl, ok := m.cfg.Template(req.Template)
+	if !ok {
+		return req, nil, fmt.Errorf("%w %q", ErrUnknownTemplate, req.Template)
+	}
+	if req.Name == "" {
+		req.Name = tpl.Name
+	}
+	if req.CWD == "" {
+		req.CWD = tpl.CWD
+	}
+	if req.StartCmd == "" {
+		req.StartCmd = tpl.StartCmd
+	}
+	if req.Tags == nil {
+		req.Tags = tpl.Tags
+	}
+	if req.Prompt == "" {
+		req.Prompt = tpl.Prompt
+	}
+	return req, tpl.Env, nil
+}
+
+// sendInitialPrompt types prompt into a new session and presses Enter once
+// its program is ready: the agent state rules see an idle prompt, the
+// screen has settled, or promptTimeout has passed.
+func (m *Manager) sendInitialPrompt(id, prompt string) {
+	m.mu.RLock()
+	s, ok := m.sessions[id]
+	var tmuxName string
+	if ok {
+		tmuxName = s.TmuxName
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	start := time.Now()
+	var last string
+	lastChange := start
+	for time.Since(start) < promptTimeout {
+		time.Sleep(promptPollInterval)
+		screen, err := m.mux.CapturePane(tmuxName, false)
+		if err != nil {
+			// The session exited or was killed
+			return
+		}
+		if m.agents.detect(screen) == AgentIdle {
+			break
+		}
+		if screen != last {
+			last, lastChange = screen, time.Now()
+		} else if strings.TrimSpace(screen) != "" && time.Since(lastChange) >= promptSettle {
+			break
+		}
+	}
+
+	err := m.Paste(id, "", prompt, true)
+	if errors.Is(err, ErrUnsupported) {
+		err = m.SendText(id, "", prompt)
+	}
+	if err != nil {
+		log.Printf("sessions: initial prompt for %q: %v", tmuxName, err)
+	}
+}
@@ -0,0 +1,70 @@
+package sessions
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Terminal is a multiplexer client attached to a session under a pseudo-terminal.
+// It backs the built-in WebSocket terminal as an alternative to ttyd.
+// Closing it detaches the client; the session keeps running.
+type Terminal struct {
+	pty  *os.File
+	cmd  *exec.Cmd
+	once sync.Once
+}
+
+// attachTerminal runs the multiplexer's attach command line under a
+// pseudo-terminal of the given size.
+func attachTerminal(attach []string, cols, rows int) (*Terminal, error) {
+	cmd := exec.Command(attach[0], attach[1:]...)
+	cmd.Env = terminalEnv()
+	pty, err := startInPTY(cmd, cols, rows)
+	if err != nil {
+		return nil, err
+	}
+	return &Terminal{pty: pty, cmd: cmd}, nil
+}
+
+// Read reads terminal output.
+func (t *Terminal) Read(p []byte) (int, error) {
+	return t.pty.Read(p)
+}
+
+// Write sends keyboard input to the terminal.
+func (t *Terminal) Write(p []byte) (int, error) {
+	return t.pty.Write(p)
+}
+
+// Resize changes the terminal size; tmux picks it up via SIGWINCH.
+func (t *Terminal) Resize(cols, rows int) error {
+	return setWinsize(t.pty, cols, rows)
+}
+
+// Close detaches the tmux client and releases the pseudo-terminal.
+func (t *Terminal) Close() error {
+	t.once.Do(func() {
+		if t.cmd.Process != nil {
+			_ = t.cmd.Process.Kill()
+		}
+		t.pty.Close()
+		_ = t.cmd.Wait()
+	})
+	return nil
+}
+
+// terminalEnv returns the gateway environment prepared for a tmux client:
+// an xterm.js-compatible TERM, and no TMUX variable so attaching works even
+// when the gateway itself runs inside tmux.
+func terminalEnv() []string {
+	env := make([]string, 0, len(os.Environ())+1)
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "TMUX=") || strings.HasPrefix(kv, "TERM=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env, "TERM=xterm-256color")
+}
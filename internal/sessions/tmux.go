@@ -1,38 +1,248 @@
 package sessions
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/user/cc-web/internal/config"
 )
 
 // TmuxRunner executes tmux commands for session management.
-type TmuxRunner struct{}
+// While a control-mode connection is up (see StartControl) commands go
+// through it; otherwise each command spawns its own tmux process.
+type TmuxRunner struct {
+	mu  sync.RWMutex
+	ctl *controlClient
+
+	server    []string          // -L/-S and -f flags; nil for the user's server
+	dedicated bool              // server is the gateway's own (tmux_socket)
+	options   map[string]string // tmux_options applied to new sessions
+}
 
+// NewTmuxRunner returns a runner for the user's default tmux server.
 func NewTmuxRunner() *TmuxRunner {
 	return &TmuxRunner{}
 }
 
+// NewTmuxRunnerFromConfig returns a runner for the server selected by
+// tmux_socket that applies tmux_options to new sessions, on top of
+// remain-on-exit on and, with follow_latest_client, window-size latest. A
+// dedicated server is started with a generated config file (next to
+// sessions_file) holding the options, sourcing tmux_config if set, so
+// ~/.tmux.conf is not read.
+func NewTmuxRunnerFromConfig(cfg *config.Config) *TmuxRunner {
+	// remain-on-exit keeps a finished pane, with its final screen and exit
+	// status, until the monitor has recorded them and killed the session
+	// (see ExitedSessions)
+	options := map[string]string{"remain-on-exit": "on"}
+	if cfg.FollowLatestClient {
+		options["window-size"] = "latest"
+	}
+	for name, value := range cfg.TmuxOptions {
+		options[name] = value
+	}
+	t := &TmuxRunner{options: options}
+	if cfg.TmuxSocket == "" {
+		return t
+	}
+	t.dedicated = true
+	if strings.Contains(cfg.TmuxSocket, "/") {
+		t.server = []string{"-S", cfg.TmuxSocket}
+	} else {
+		t.server = []string{"-L", cfg.TmuxSocket}
+	}
+
+	confPath := filepath.Join(filepath.Dir(cfg.SessionsFile), "tmux.conf")
+	if err := writeTmuxConf(confPath, cfg.TmuxConfig, t.options); err != nil {
+		log.Printf("sessions: write %s: %v; starting tmux without a config file", confPath, err)
+		confPath = os.DevNull
+	}
+	t.server = append(t.server, "-f", confPath)
+	return t
+}
+
+// writeTmuxConf writes the config file a dedicated tmux server starts with.
+func writeTmuxConf(path, include string, options map[string]string) error {
+	var b strings.Builder
+	b.WriteString("# Generated by cc-web from tmux_config and tmux_options; rewritten on startup.\n")
+	if include != "" {
+		b.WriteString(controlCommand([]string{"source-file", include}) + "\n")
+	}
+	for _, name := range sortedKeys(options) {
+		b.WriteString(controlCommand([]string{"set-option", "-g", name, options[name]}) + "\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// serverArgs returns the flags selecting the tmux server. Without
+// tmux_socket, clients started with terminalEnv have no TMUX variable (tmux
+// refuses to attach from inside a session), so when the gateway runs inside
+// tmux, point them at the socket TMUX named.
+func (t *TmuxRunner) serverArgs() []string {
+	if t.server != nil {
+		return t.server
+	}
+	if v := os.Getenv("TMUX"); v != "" {
+		socket, _, _ := strings.Cut(v, ",")
+		return []string{"-S", socket}
+	}
+	return nil
+}
+
+// StartControl opens a control-mode connection and routes later commands
+// through it. onNotify receives tmux notifications such as
+// "sessions-changed"; it runs on the connection's reader goroutine and must
+// not call back into the runner. The returned channel is closed when the
+// connection ends, after which commands fall back to exec again.
+func (t *TmuxRunner) StartControl(onNotify func(controlNotification)) (<-chan struct{}, error) {
+	c, err := startControlClient(t.serverArgs(), onNotify)
+	if err != nil {
+		return nil, err
+	}
+	// Probe the connection so a broken setup falls back immediately
+	if _, err := c.run([]string{"display-message", "-p", "ok"}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	t.mu.Lock()
+	t.ctl = c
+	t.mu.Unlock()
+
+	go func() {
+		<-c.Done()
+		t.mu.Lock()
+		if t.ctl == c {
+			t.ctl = nil
+		}
+		t.mu.Unlock()
+	}()
+	return c.Done(), nil
+}
+
+// StopControl closes the control-mode connection, if any.
+func (t *TmuxRunner) StopControl() {
+	t.mu.Lock()
+	c := t.ctl
+	t.ctl = nil
+	t.mu.Unlock()
+	if c != nil {
+		c.Close()
+	}
+}
+
+// run executes one tmux command and returns its standard output. On
+// failure the error carries tmux's message, formatted like
+// "<message>: exit status 1".
+func (t *TmuxRunner) run(args ...string) (string, error) {
+	t.mu.RLock()
+	c := t.ctl
+	t.mu.RUnlock()
+	if c != nil {
+		res, err := c.run(args)
+		if err == nil {
+			out := strings.Join(res.lines, "\n")
+			if res.failed {
+				return "", fmt.Errorf("%s: command failed", out)
+			}
+			if len(res.lines) > 0 {
+				out += "\n"
+			}
+			return out, nil
+		}
+		if !errors.Is(err, errControlClosed) {
+			return "", err
+		}
+		// Connection dropped mid-call; retry the old way
+	}
+
+	return t.exec(nil, args...)
+}
+
+// exec runs one tmux command in its own process, feeding it stdin.
+func (t *TmuxRunner) exec(stdin io.Reader, args ...string) (string, error) {
+	cmd := exec.Command("tmux", append(append([]string(nil), t.serverArgs()...), args...)...)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", stderr.String(), err)
+	}
+	return string(out), nil
+}
+
 // CreateSession creates a new tmux session with the given name, working directory, and command.
-func (t *TmuxRunner) CreateSession(tmuxName, cwd, startCmd string) error {
+func (t *TmuxRunner) CreateSession(tmuxName, cwd, startCmd string, env map[string]string) error {
+	// A pane's scrollback size is fixed when it is created. On the user's
+	// server history-limit can only be set on the session, after
+	// new-session made its first pane, so the command runs in a second
+	// window opened once the options are set, and the first is closed.
+	placeholder := !t.dedicated && t.options["history-limit"] != ""
 	args := []string{"new-session", "-d", "-s", tmuxName, "-c", cwd}
-	if startCmd != "" {
+	for _, name := range sortedKeys(env) {
+		args = append(args, "-e", name+"="+env[name])
+	}
+	if placeholder {
+		args = append(args, "--", "cat")
+	} else if startCmd != "" {
 		args = append(args, "--", startCmd)
 	}
-	cmd := exec.Command("tmux", args...)
-	out, err := cmd.CombinedOutput()
+	if _, err := t.run(args...); err != nil {
+		return fmt.Errorf("tmux new-session: %w", err)
+	}
+	if err := t.applyOptions(tmuxName); err != nil || !placeholder {
+		return err
+	}
+	first, err := t.run("display-message", "-p", "-t", tmuxName, "#{window_id}")
 	if err != nil {
-		return fmt.Errorf("tmux new-session: %s: %w", string(out), err)
+		return fmt.Errorf("tmux display-message: %w", err)
+	}
+	args = []string{"new-window", "-t", tmuxName, "-c", cwd}
+	if startCmd != "" {
+		args = append(args, "--", startCmd)
+	}
+	if _, err := t.run(args...); err != nil {
+		t.KillSession(tmuxName)
+		return fmt.Errorf("tmux new-window: %w", err)
+	}
+	if _, err := t.run("kill-window", "-t", strings.TrimSpace(first)); err != nil {
+		return fmt.Errorf("tmux kill-window: %w", err)
+	}
+	return nil
+}
+
+// applyOptions sets tmux_options on a new session. On a dedicated server
+// they are set globally (the config file already did so at server start;
+// this picks up changes made while it kept running). On the user's server
+// they are set on the session only, which is why CreateSession opens the
+// command's window after them.
+func (t *TmuxRunner) applyOptions(tmuxName string) error {
+	for _, name := range sortedKeys(t.options) {
+		args := []string{"set-option", "-t", tmuxName, name, t.options[name]}
+		if t.dedicated {
+			args = []string{"set-option", "-g", name, t.options[name]}
+		}
+		if _, err := t.run(args...); err != nil {
+			return fmt.Errorf("tmux set-option %s: %w", name, err)
+		}
 	}
 	return nil
 }
 
 // KillSession kills the tmux session.
 func (t *TmuxRunner) KillSession(tmuxName string) error {
-	cmd := exec.Command("tmux", "kill-session", "-t", tmuxName)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("tmux kill-session: %s: %w", string(out), err)
+	if _, err := t.run("kill-session", "-t", tmuxName); err != nil {
+		return fmt.Errorf("tmux kill-session: %w", err)
 	}
 	return nil
 }
@@ -41,69 +251,367 @@ func (t *TmuxRunner) KillSession(tmuxName string) error {
 // Uses -l flag to prevent text like "Enter" being interpreted as key names.
 func (t *TmuxRunner) SendKeys(tmuxName, text string) error {
 	// Send text literally (no key interpretation)
-	cmd := exec.Command("tmux", "send-keys", "-t", tmuxName, "-l", text)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("tmux send-keys: %s: %w", string(out), err)
+	if _, err := t.run("send-keys", "-t", tmuxName, "-l", text); err != nil {
+		return fmt.Errorf("tmux send-keys: %w", err)
 	}
 	// Send Enter separately as a key name
-	cmd2 := exec.Command("tmux", "send-keys", "-t", tmuxName, "Enter")
-	out2, err := cmd2.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("tmux send-keys Enter: %s: %w", string(out2), err)
+	if _, err := t.run("send-keys", "-t", tmuxName, "Enter"); err != nil {
+		return fmt.Errorf("tmux send-keys Enter: %w", err)
+	}
+	return nil
+}
+
+// Paste loads text into a uniquely named tmux buffer and pastes it with
+// bracketed paste, so an application that asks for it (like Claude's
+// input box) receives multi-line text as one paste rather than one line per
+// Enter. The buffer is loaded over stdin, which control mode cannot
+// carry, so it always runs in its own process; there is no argv limit.
+// The buffer is deleted after pasting. With enter set, Enter follows.
+func (t *TmuxRunner) Paste(tmuxName, text string, enter bool) error {
+	buffer := "cc-web-paste-" + randomSuffix()
+	if _, err := t.exec(strings.NewReader(text), "load-buffer", "-b", buffer, "-"); err != nil {
+		return fmt.Errorf("tmux load-buffer: %w", err)
+	}
+	if _, err := t.run("paste-buffer", "-p", "-d", "-b", buffer, "-t", tmuxName); err != nil {
+		_, _ = t.run("delete-buffer", "-b", buffer)
+		return fmt.Errorf("tmux paste-buffer: %w", err)
+	}
+	if enter {
+		if _, err := t.run("send-keys", "-t", tmuxName, "Enter"); err != nil {
+			return fmt.Errorf("tmux send-keys Enter: %w", err)
+		}
 	}
 	return nil
 }
 
 // SendRawKeys sends raw key tokens to the tmux session (no implicit Enter).
+// Runs of raw bytes ("0xNN") go in one send-keys -H so bytes above 0x7f are
+// not taken for Unicode code points.
 func (t *TmuxRunner) SendRawKeys(tmuxName string, keys []string) error {
-	args := []string{"send-keys", "-t", tmuxName, "--"}
-	args = append(args, keys...)
-	cmd := exec.Command("tmux", args...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("tmux send-keys: %s: %w", string(out), err)
+	for len(keys) > 0 {
+		raw := isHexKey(keys[0])
+		n := 1
+		for n < len(keys) && isHexKey(keys[n]) == raw {
+			n++
+		}
+		args := []string{"send-keys", "-t", tmuxName}
+		if raw {
+			args = append(args, "-H", "--")
+			for _, k := range keys[:n] {
+				args = append(args, k[2:])
+			}
+		} else {
+			args = append(append(args, "--"), keys[:n]...)
+		}
+		if _, err := t.run(args...); err != nil {
+			return fmt.Errorf("tmux send-keys: %w", err)
+		}
+		keys = keys[n:]
 	}
 	return nil
 }
 
 // Interrupt sends Ctrl+C to the tmux session.
 func (t *TmuxRunner) Interrupt(tmuxName string) error {
-	cmd := exec.Command("tmux", "send-keys", "-t", tmuxName, "C-c")
-	out, err := cmd.CombinedOutput()
+	if _, err := t.run("send-keys", "-t", tmuxName, "C-c"); err != nil {
+		return fmt.Errorf("tmux interrupt: %w", err)
+	}
+	return nil
+}
+
+// CapturePane returns the visible contents of the session's active pane.
+// With escapes set, text attributes and colors are kept as ANSI sequences.
+func (t *TmuxRunner) CapturePane(tmuxName string, escapes bool) (string, error) {
+	args := []string{"capture-pane", "-p", "-t", tmuxName}
+	if escapes {
+		args = append(args, "-e")
+	}
+	out, err := t.run(args...)
+	if err != nil {
+		return "", fmt.Errorf("tmux capture-pane: %w", err)
+	}
+	return out, nil
+}
+
+// PaneSize returns the number of scrollback lines and the visible height of
+// the session's active pane.
+func (t *TmuxRunner) PaneSize(tmuxName string) (history, height int, err error) {
+	out, err := t.run("display-message", "-p", "-t", tmuxName, "#{history_size} #{pane_height}")
 	if err != nil {
-		return fmt.Errorf("tmux interrupt: %s: %w", string(out), err)
+		return 0, 0, fmt.Errorf("tmux display-message: %w", err)
+	}
+	if _, err := fmt.Sscanf(strings.TrimSpace(out), "%d %d", &history, &height); err != nil {
+		return 0, 0, fmt.Errorf("tmux display-message: unexpected output %q", out)
+	}
+	return history, height, nil
+}
+
+// WindowSize returns the width and height of the session's active pane.
+func (t *TmuxRunner) WindowSize(tmuxName string) (cols, rows int, err error) {
+	out, err := t.run("display-message", "-p", "-t", tmuxName, "#{pane_width} #{pane_height}")
+	if err != nil {
+		return 0, 0, fmt.Errorf("tmux display-message: %w", err)
+	}
+	if _, err := fmt.Sscanf(strings.TrimSpace(out), "%d %d", &cols, &rows); err != nil {
+		return 0, 0, fmt.Errorf("tmux display-message: unexpected output %q", out)
+	}
+	return cols, rows, nil
+}
+
+// RespawnDeadPanes restarts the dead panes of a session (left behind by
+// remain-on-exit) with the command they were started with.
+func (t *TmuxRunner) RespawnDeadPanes(tmuxName string) (int, error) {
+	out, err := t.run("list-panes", "-s", "-t", tmuxName, "-F", "#{pane_dead} #{pane_id}")
+	if err != nil {
+		return 0, fmt.Errorf("tmux list-panes: %w", err)
+	}
+	n := 0
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		dead, paneID, ok := strings.Cut(line, " ")
+		if !ok || dead != "1" {
+			continue
+		}
+		if _, err := t.run("respawn-pane", "-t", paneID); err != nil {
+			return n, fmt.Errorf("tmux respawn-pane: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ResizeWindow sets the size of a session's current window, or of the
+// window a "<name>:<target>" refers to. resize-window switches the window to
+// window-size manual; a window-size set in the options (follow_latest_client)
+// is put back, so the window follows its clients again once one is active.
+func (t *TmuxRunner) ResizeWindow(tmuxName string, cols, rows int) error {
+	if _, err := t.run("resize-window", "-t", tmuxName, "-x", strconv.Itoa(cols), "-y", strconv.Itoa(rows)); err != nil {
+		return fmt.Errorf("tmux resize-window: %w", err)
+	}
+	if size, ok := t.options["window-size"]; ok && size != "manual" {
+		if _, err := t.run("set-option", "-w", "-t", tmuxName, "window-size", size); err != nil {
+			return fmt.Errorf("tmux set-option window-size: %w", err)
+		}
+	}
+	return nil
+}
+
+// CaptureRange returns pane lines from start to end inclusive, using tmux
+// line numbering: 0 is the first visible line, negative numbers reach into
+// the scrollback history.
+func (t *TmuxRunner) CaptureRange(tmuxName string, start, end int) ([]string, error) {
+	out, err := t.run("capture-pane", "-p", "-t", tmuxName,
+		"-S", strconv.Itoa(start), "-E", strconv.Itoa(end))
+	if err != nil {
+		return nil, fmt.Errorf("tmux capture-pane: %w", err)
+	}
+	text := strings.TrimSuffix(out, "\n")
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
+
+// PipePane pipes the output of the session's active pane to shellCmd.
+// An empty shellCmd closes any existing pipe.
+func (t *TmuxRunner) PipePane(tmuxName, shellCmd string) error {
+	args := []string{"pipe-pane", "-t", tmuxName}
+	if shellCmd != "" {
+		args = append(args, shellCmd)
+	}
+	if _, err := t.run(args...); err != nil {
+		return fmt.Errorf("tmux pipe-pane: %w", err)
 	}
 	return nil
 }
 
 // HasSession checks if a tmux session exists.
 func (t *TmuxRunner) HasSession(tmuxName string) bool {
-	cmd := exec.Command("tmux", "has-session", "-t", tmuxName)
-	return cmd.Run() == nil
+	_, err := t.run("has-session", "-t", tmuxName)
+	return err == nil
 }
 
-// ListSessions returns all tmux session names.
+// ListSessions returns all tmux session names, except the gateway's own
+// control-mode session.
 func (t *TmuxRunner) ListSessions() ([]string, error) {
-	cmd := exec.Command("tmux", "list-sessions", "-F", "#{session_name}")
-	out, err := cmd.CombinedOutput()
+	out, err := t.run("list-sessions", "-F", "#{session_name}")
 	if err != nil {
 		// tmux not available or no sessions — all acceptable
-		outStr := string(out)
-		if strings.Contains(outStr, "no server running") ||
-			strings.Contains(outStr, "no sessions") ||
-			strings.Contains(outStr, "error connecting") {
+		msg := err.Error()
+		if strings.Contains(msg, "no server running") ||
+			strings.Contains(msg, "no sessions") ||
+			strings.Contains(msg, "error connecting") {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("tmux list-sessions: %s: %w", string(out), err)
+		return nil, fmt.Errorf("tmux list-sessions: %w", err)
 	}
-	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
 	var result []string
 	for _, l := range lines {
 		l = strings.TrimSpace(l)
-		if l != "" {
+		if l != "" && l != controlSessionName {
 			result = append(result, l)
 		}
 	}
 	return result, nil
 }
+
+// ExitedSessions returns the sessions whose panes have all exited and are
+// only kept by remain-on-exit, with the exit status of their first pane:
+// nil if it was killed by a signal, or tmux has not reaped the process yet.
+func (t *TmuxRunner) ExitedSessions() (map[string]*int, error) {
+	out, err := t.run("list-panes", "-a", "-F", "#{session_name}\t#{pane_dead}\t#{pane_dead_status}")
+	if err != nil {
+		msg := err.Error()
+		if strings.Contains(msg, "no server running") || strings.Contains(msg, "error connecting") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("tmux list-panes: %w", err)
+	}
+	exited := map[string]*int{}
+	live := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		name := fields[0]
+		if fields[1] != "1" {
+			live[name] = true
+			continue
+		}
+		if _, seen := exited[name]; seen {
+			continue
+		}
+		var code *int
+		if n, err := strconv.Atoi(fields[2]); err == nil {
+			code = &n
+		}
+		exited[name] = code
+	}
+	for name := range live {
+		delete(exited, name)
+	}
+	return exited, nil
+}
+
+// PipeOutput appends the output of the session's active pane to path.
+func (t *TmuxRunner) PipeOutput(tmuxName, path string) error {
+	return t.PipePane(tmuxName, "cat >> "+shellQuote(path))
+}
+
+// AttachCommand returns the command line of an interactive tmux client.
+func (t *TmuxRunner) AttachCommand(tmuxName string) []string {
+	return append(append([]string{"tmux"}, t.serverArgs()...), "attach-session", "-t", tmuxName)
+}
+
+// windowFormat is the list-panes format parsed by ListWindows.
+const windowFormat = "#{window_index}\t#{window_id}\t#{window_active}\t" +
+	"#{pane_index}\t#{pane_id}\t#{pane_active}\t#{pane_width}\t#{pane_height}\t" +
+	"#{pane_current_command}\t#{window_name}"
+
+// ListWindows returns the windows of a session with their panes.
+func (t *TmuxRunner) ListWindows(tmuxName string) ([]Window, error) {
+	out, err := t.run("list-panes", "-s", "-t", tmuxName, "-F", windowFormat)
+	if err != nil {
+		return nil, fmt.Errorf("tmux list-panes: %w", err)
+	}
+	var windows []Window
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		f := strings.SplitN(line, "\t", 10)
+		if len(f) != 10 {
+			continue
+		}
+		wi, _ := strconv.Atoi(f[0])
+		pi, _ := strconv.Atoi(f[3])
+		width, _ := strconv.Atoi(f[6])
+		height, _ := strconv.Atoi(f[7])
+		if len(windows) == 0 || windows[len(windows)-1].Index != wi {
+			windows = append(windows, Window{Index: wi, ID: f[1], Name: f[9], Active: f[2] == "1"})
+		}
+		w := &windows[len(windows)-1]
+		w.Panes = append(w.Panes, Pane{
+			Index:   pi,
+			ID:      f[4],
+			Target:  fmt.Sprintf("%d.%d", wi, pi),
+			Active:  f[5] == "1",
+			Width:   width,
+			Height:  height,
+			Command: f[8],
+		})
+	}
+	return windows, nil
+}
+
+// NewWindow creates a window at the next free index without selecting it.
+func (t *TmuxRunner) NewWindow(tmuxName, windowName, cwd, cmd string) (int, error) {
+	args := []string{"new-window", "-d", "-P", "-F", "#{window_index}", "-t", tmuxName + ":", "-c", cwd}
+	if windowName != "" {
+		args = append(args, "-n", windowName)
+	}
+	if cmd != "" {
+		args = append(args, "--", cmd)
+	}
+	out, err := t.run(args...)
+	if err != nil {
+		return 0, fmt.Errorf("tmux new-window: %w", err)
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return 0, fmt.Errorf("tmux new-window: unexpected output %q", out)
+	}
+	return index, nil
+}
+
+// SplitPane splits the active pane of a window without selecting the new pane.
+func (t *TmuxRunner) SplitPane(tmuxName, window string, horizontal bool, cwd, cmd string) (string, error) {
+	args := []string{"split-window", "-d", "-P", "-F", "#{window_index}.#{pane_index}",
+		"-t", tmuxName + ":" + window, "-c", cwd}
+	if horizontal {
+		args = append(args, "-h")
+	}
+	if cmd != "" {
+		args = append(args, "--", cmd)
+	}
+	out, err := t.run(args...)
+	if err != nil {
+		return "", fmt.Errorf("tmux split-window: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// SelectTarget makes a window, or a pane and its window, active.
+func (t *TmuxRunner) SelectTarget(tmuxName, target string) error {
+	window, _, isPane := strings.Cut(target, ".")
+	if _, err := t.run("select-window", "-t", tmuxName+":"+window); err != nil {
+		return fmt.Errorf("tmux select-window: %w", err)
+	}
+	if isPane {
+		if _, err := t.run("select-pane", "-t", tmuxName+":"+target); err != nil {
+			return fmt.Errorf("tmux select-pane: %w", err)
+		}
+	}
+	return nil
+}
+
+// KillTarget closes a window or a pane. Closing the last one ends the session.
+func (t *TmuxRunner) KillTarget(tmuxName, target string) error {
+	if strings.Contains(target, ".") {
+		if _, err := t.run("kill-pane", "-t", tmuxName+":"+target); err != nil {
+			return fmt.Errorf("tmux kill-pane: %w", err)
+		}
+		return nil
+	}
+	if _, err := t.run("kill-window", "-t", tmuxName+":"+target); err != nil {
+		return fmt.Errorf("tmux kill-window: %w", err)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,37 +1,14 @@
 package sessions
 
 import (
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
-)
-
-func TestMapKey(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"ESC", "Escape"},
-		{"esc", "Escape"},
-		{"UP", "Up"},
-		{"DOWN", "Down"},
-		{"TAB", "Tab"},
-		{"ENTER", "Enter"},
-		{"CTRL_C", "C-c"},
-		{"CTRL+C", "C-c"},
-		{"CTRL_D", "C-d"},
-		{"BACKSPACE", "BSpace"},
-		{"SPACE", "Space"},
-		{"a", "a"},
-		{"hello", "hello"},
-	}
+	"time"
 
-	for _, tt := range tests {
-		got := mapKey(tt.input)
-		if got != tt.expected {
-			t.Errorf("mapKey(%q) = %q, want %q", tt.input, got, tt.expected)
-		}
-	}
-}
+	"github.com/user/cc-web/internal/config"
+)
 
 func TestSanitizeName(t *testing.T) {
 	tests := []struct {
@@ -65,3 +42,203 @@ func TestTmuxRunnerListSessions(t *testing.T) {
 	}
 	_ = sessions
 }
+
+// newTestTmuxRunner returns a runner for a dedicated tmux server, configured
+// by cfg with the socket and sessions file in a temporary directory, which
+// is also returned. The test is skipped if tmux is not installed, and the
+// server is killed when it ends.
+func newTestTmuxRunner(t *testing.T, cfg config.Config) (*TmuxRunner, string) {
+	t.Helper()
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	dir := t.TempDir()
+	cfg.SessionsFile = filepath.Join(dir, "sessions.json")
+	cfg.TmuxSocket = filepath.Join(dir, "tmux.sock")
+	t.Cleanup(func() { exec.Command("tmux", "-S", cfg.TmuxSocket, "kill-server").Run() })
+	return NewTmuxRunnerFromConfig(&cfg), dir
+}
+
+func TestTmuxRunnerDedicatedServer(t *testing.T) {
+	runner, dir := newTestTmuxRunner(t, config.Config{
+		TmuxOptions:        map[string]string{"history-limit": "12345", "status": "off"},
+		FollowLatestClient: true,
+	})
+
+	if err := runner.CreateSession("claude-dedicated", dir, "", map[string]string{"CC_WEB_TEST": "a b"}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if out, err := runner.run("show-environment", "-t", "claude-dedicated", "CC_WEB_TEST"); err != nil || strings.TrimSpace(out) != "CC_WEB_TEST=a b" {
+		t.Errorf("session environment = %q, %v", out, err)
+	}
+	for name, want := range map[string]string{"history-limit": "12345", "status": "off"} {
+		out, err := runner.run("show-options", "-gv", name)
+		if err != nil {
+			t.Fatalf("show-options %s: %v", name, err)
+		}
+		if got := strings.TrimSpace(out); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	// The first pane was created after the config file set history-limit.
+	out, err := runner.run("display-message", "-p", "-t", "claude-dedicated", "#{history_limit}")
+	if err != nil {
+		t.Fatalf("display-message: %v", err)
+	}
+	if got := strings.TrimSpace(out); got != "12345" {
+		t.Errorf("pane history_limit = %q, want 12345", got)
+	}
+	if err := runner.ResizeWindow("claude-dedicated", 100, 30); err != nil {
+		t.Fatalf("ResizeWindow: %v", err)
+	}
+	if cols, rows, err := runner.WindowSize("claude-dedicated"); err != nil || cols != 100 || rows != 30 {
+		t.Errorf("WindowSize = %dx%d, %v; want 100x30", cols, rows, err)
+	}
+	if out, err := runner.run("show-options", "-wv", "-t", "claude-dedicated", "window-size"); err != nil || strings.TrimSpace(out) != "latest" {
+		t.Errorf("window-size after resize = %q, %v; want latest", out, err)
+	}
+	socket := filepath.Join(dir, "tmux.sock")
+	if args := runner.AttachCommand("claude-dedicated"); args[1] != "-S" || args[2] != socket {
+		t.Errorf("AttachCommand = %v, want it pinned to %s", args, socket)
+	}
+}
+
+func TestTmuxRunnerPaste(t *testing.T) {
+	runner, dir := newTestTmuxRunner(t, config.Config{})
+
+	if err := runner.CreateSession("claude-paste", dir, "cat", nil); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	text := "first line\n" + strings.Repeat("x", 200000) + "\nlast line"
+	if err := runner.Paste("claude-paste", text, true); err != nil {
+		t.Fatalf("Paste: %v", err)
+	}
+	var screen string
+	for i := 0; i < 50; i++ {
+		screen, _ = runner.CapturePane("claude-paste", false)
+		if strings.Count(screen, "last line") == 2 { // echoed by the tty, then by cat
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !strings.Contains(screen, "last line") {
+		t.Errorf("pasted text not on screen:\n%s", screen)
+	}
+	if out, _ := runner.run("list-buffers"); strings.Contains(out, "cc-web-paste-") {
+		t.Errorf("paste buffer left behind: %s", out)
+	}
+}
+
+func TestTmuxRunnerSendRawKeys(t *testing.T) {
+	runner, dir := newTestTmuxRunner(t, config.Config{})
+
+	if err := runner.CreateSession("claude-keys", dir, "cat -v", nil); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	keys, err := ParseKeys([]string{"a", "0x1b5b41", ";", "C-Left", "SHIFT+TAB", "ENTER"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.SendRawKeys("claude-keys", keys); err != nil {
+		t.Fatalf("SendRawKeys: %v", err)
+	}
+	want := "a^[[A;^[[1;5D^[[Z"
+	var screen string
+	for i := 0; i < 50; i++ {
+		screen, _ = runner.CapturePane("claude-keys", false)
+		if strings.Count(screen, want) == 2 { // echoed by the tty, then by cat -v
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf("screen does not show %q twice:\n%s", want, screen)
+}
+
+func TestTmuxRunnerRespawnDeadPanes(t *testing.T) {
+	runner, dir := newTestTmuxRunner(t, config.Config{
+		TmuxOptions: map[string]string{"remain-on-exit": "on"},
+	})
+
+	if err := runner.CreateSession("claude-respawn", dir, "echo started", nil); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	paneDead := func() string {
+		out, _ := runner.run("display-message", "-p", "-t", "claude-respawn", "#{pane_dead}")
+		return strings.TrimSpace(out)
+	}
+	for i := 0; i < 50 && paneDead() != "1"; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if paneDead() != "1" {
+		t.Fatal("pane did not die")
+	}
+	n, err := runner.RespawnDeadPanes("claude-respawn")
+	if err != nil || n != 1 {
+		t.Fatalf("RespawnDeadPanes = %d, %v; want 1", n, err)
+	}
+}
+
+func TestTmuxRunnerExitedSessions(t *testing.T) {
+	runner, dir := newTestTmuxRunner(t, config.Config{})
+
+	if err := runner.CreateSession("claude-exit", dir, "echo bye; sleep 0.2; exit 7", nil); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := runner.CreateSession("claude-live", dir, "cat", nil); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	// Wait on the pane itself, so a slow machine only makes the test slower
+	for deadline := time.Now().Add(30 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		dead, err := runner.run("display-message", "-p", "-t", "claude-exit", "#{pane_dead}")
+		if err != nil {
+			t.Fatalf("display-message: %v", err)
+		}
+		if strings.TrimSpace(dead) == "1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("claude-exit pane still running, pane_dead = %q", dead)
+		}
+	}
+	exited, err := runner.ExitedSessions()
+	if err != nil {
+		t.Fatalf("ExitedSessions: %v", err)
+	}
+	code, ok := exited["claude-exit"]
+	// tmux may report the pane dead before it has reaped the process
+	if len(exited) != 1 || !ok || (code != nil && *code != 7) {
+		t.Fatalf("ExitedSessions = %v", exited)
+	}
+	m := NewManagerWithMultiplexer(&config.Config{}, runner)
+	if screen, err := m.finalScreen("claude-exit"); err != nil || screen != "bye" {
+		t.Errorf("final screen = %q, %v; want %q", screen, err, "bye")
+	}
+}
+
+func TestTmuxRunnerDefaultServerHistoryLimit(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	// Private server standing in for the user's default one
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "tmux.sock")
+	t.Setenv("TMUX", socket+",0,0")
+	t.Cleanup(func() { exec.Command("tmux", "-S", socket, "kill-server").Run() })
+	runner := NewTmuxRunnerFromConfig(&config.Config{
+		TmuxOptions: map[string]string{"history-limit": "12345"},
+	})
+
+	if err := runner.CreateSession("claude-hist", dir, "cat", map[string]string{"CC_WEB_TEST": "1"}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	out, err := runner.run("list-panes", "-s", "-t", "claude-hist", "-F", "#{history_limit} #{pane_current_command}")
+	if err != nil {
+		t.Fatalf("list-panes: %v", err)
+	}
+	if got := strings.TrimSpace(out); got != "12345 cat" {
+		t.Errorf("panes = %q, want one cat pane with history_limit 12345", got)
+	}
+	if out, err := runner.run("show-environment", "-t", "claude-hist", "CC_WEB_TEST"); err != nil || strings.TrimSpace(out) != "CC_WEB_TEST=1" {
+		t.Errorf("session environment = %q, %v", out, err)
+	}
+}
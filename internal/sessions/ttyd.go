@@ -64,8 +64,9 @@ func (t *TtydManager) ReleasePort(port int) {
 	delete(t.usedPorts, port)
 }
 
-// Start launches a ttyd process that attaches to the given tmux session.
-func (t *TtydManager) Start(tmuxName string, port int) error {
+// Start launches a ttyd process that runs attach, the multiplexer's
+// command line for attaching to the given session.
+func (t *TtydManager) Start(tmuxName string, port int, attach []string) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -89,13 +90,13 @@ func (t *TtydManager) Start(tmuxName string, port int) error {
 	}
 
 	// tmuxName already contains the TmuxPrefix — use it directly in base-path
-	cmd := exec.Command(t.ttydPath,
+	args := []string{
 		"--port", fmt.Sprintf("%d", port),
 		"--interface", "127.0.0.1",
 		"--writable",
 		"--base-path", fmt.Sprintf("/t/%s/", tmuxName),
-		"tmux", "attach-session", "-t", tmuxName,
-	)
+	}
+	cmd := exec.Command(t.ttydPath, append(args, attach...)...)
 
 	// Capture ttyd output for debugging
 	cmd.Stdout = &logWriter{prefix: fmt.Sprintf("ttyd[%s]", tmuxName)}
@@ -0,0 +1,149 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUploadTooLarge is returned when an upload exceeds upload_max_mb.
+	ErrUploadTooLarge = errors.New("upload exceeds the size limit")
+	// ErrUploadNotAllowed is returned when the upload directory is outside
+	// projects_allowed or leads through a symlink.
+	ErrUploadNotAllowed = errors.New("upload directory is not in allowed list")
+)
+
+// uploadNameUnsafe matches characters dropped from uploaded file names.
+var uploadNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// pathSafeChars are the characters a typed path may hold without quoting.
+const pathSafeChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789/._-+=:@"
+
+// maxUploadNameLen bounds the sanitized file name, extension included.
+const maxUploadNameLen = 100
+
+// Upload is a file saved into a session's upload directory.
+type Upload struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// SaveUpload stores src under the upload_dir of a session's working
+// directory. The file name is reduced to a safe form and prefixed with the
+// time, so uploads never overwrite each other or land outside the
+// directory. The directory is created and the file written through an
+// os.Root on the working directory, so a symlink cannot redirect them
+// elsewhere. At most maxBytes are accepted.
+func (m *Manager) SaveUpload(id, name string, src io.Reader, maxBytes int64) (*Upload, error) {
+	m.mu.RLock()
+	s, ok := m.sessions[id]
+	var cwd string
+	if ok {
+		cwd = s.CWD
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return nil, &notFoundError{id: id}
+	}
+
+	dir := filepath.Clean(m.cfg.UploadDir)
+	if !m.cfg.IsPathAllowed(filepath.Join(cwd, dir)) {
+		return nil, ErrUploadNotAllowed
+	}
+	root, err := os.OpenRoot(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("open session dir: %w", err)
+	}
+	defer root.Close()
+	if err := mkdirNoSymlinks(root, dir); err != nil {
+		return nil, err
+	}
+
+	rel := filepath.Join(dir, time.Now().Format("20060102-150405")+"-"+randomSuffix()+"-"+uploadFileName(name))
+	path := filepath.Join(cwd, rel)
+	f, err := root.OpenFile(rel, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("create upload: %w", err)
+	}
+	n, err := io.Copy(f, io.LimitReader(src, maxBytes+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > maxBytes {
+		err = ErrUploadTooLarge
+	}
+	if err != nil {
+		root.Remove(rel)
+		if errors.Is(err, ErrUploadTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("write upload: %w", err)
+	}
+	return &Upload{Name: filepath.Base(path), Path: path, Size: n}, nil
+}
+
+// mkdirNoSymlinks creates dir and its parents inside root, one at a time,
+// rejecting any existing component that is a symlink.
+func mkdirNoSymlinks(root *os.Root, dir string) error {
+	if dir == "." {
+		return nil
+	}
+	cur := ""
+	for _, part := range strings.Split(dir, string(filepath.Separator)) {
+		cur = filepath.Join(cur, part)
+		info, err := root.Lstat(cur)
+		switch {
+		case err == nil && info.Mode()&os.ModeSymlink != 0:
+			return ErrUploadNotAllowed
+		case err == nil && !info.IsDir():
+			return fmt.Errorf("create upload dir: %s is not a directory", cur)
+		case err == nil:
+		case errors.Is(err, fs.ErrNotExist):
+			if err := root.Mkdir(cur, 0700); err != nil && !errors.Is(err, fs.ErrExist) {
+				return fmt.Errorf("create upload dir: %w", err)
+			}
+		default:
+			return fmt.Errorf("create upload dir: %w", err)
+		}
+	}
+	return nil
+}
+
+// TypePaths types upload paths at a session's prompt, separated by spaces
+// and without pressing Enter, so they can be referenced in the next message.
+func (m *Manager) TypePaths(id string, paths []string) error {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = p
+		if strings.Trim(p, pathSafeChars) != "" {
+			quoted[i] = shellQuote(p)
+		}
+	}
+	return m.Paste(id, "", strings.Join(quoted, " ")+" ", false)
+}
+
+// uploadFileName reduces a client-supplied file name to its base name in
+// a safe character set.
+func uploadFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Trim(uploadNameUnsafe.ReplaceAllString(name, "_"), "._")
+	if name == "" {
+		return "upload"
+	}
+	if len(name) > maxUploadNameLen {
+		ext := filepath.Ext(name)
+		if len(ext) > 10 {
+			ext = ""
+		}
+		name = name[:maxUploadNameLen-len(ext)] + ext
+	}
+	return name
+}
@@ -0,0 +1,22 @@
+package sessions
+
+import "testing"
+
+func TestUploadFileName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"photo.jpg", "photo.jpg"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\me\log file.txt`, "log_file.txt"},
+		{"..", "upload"},
+		{".bashrc", "bashrc"},
+		{"", "upload"},
+	}
+	for _, tt := range tests {
+		if got := uploadFileName(tt.input); got != tt.expected {
+			t.Errorf("uploadFileName(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// targetPattern matches window ("1") and pane ("1.0") targets.
+var targetPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+var (
+	// ErrUnsupported is returned for operations the multiplexer backend cannot perform.
+	ErrUnsupported = errors.New("not supported by the multiplexer backend")
+	// ErrInvalidTarget is returned for a malformed window or pane target.
+	ErrInvalidTarget = errors.New(`target must be "<window>" or "<window>.<pane>"`)
+	// ErrTargetNotFound is returned when a window or pane does not exist.
+	ErrTargetNotFound = errors.New("window or pane not found")
+)
+
+// ValidTarget reports whether target is a window ("1") or pane ("1.0") target.
+func ValidTarget(target string) bool {
+	return targetPattern.MatchString(target)
+}
+
+// windowSession returns the multiplexer session name and directory of a
+// running session, provided the backend supports windows.
+func (m *Manager) windowSession(id string) (WindowManager, string, string, error) {
+	m.mu.RLock()
+	s, ok := m.sessions[id]
+	var tmuxName, cwd string
+	var status Status
+	if ok {
+		tmuxName, cwd, status = s.TmuxName, s.CWD, s.Status
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return nil, "", "", &notFoundError{id: id}
+	}
+	wm, ok := m.mux.(WindowManager)
+	if !ok {
+		return nil, "", "", fmt.Errorf("windows: %w", ErrUnsupported)
+	}
+	if status == StatusExited {
+		return nil, "", "", fmt.Errorf("session %q: %w", id, ErrSessionExited)
+	}
+	return wm, tmuxName, cwd, nil
+}
+
+// Windows lists the windows and panes of a session.
+func (m *Manager) Windows(id string) ([]Window, error) {
+	wm, tmuxName, _, err := m.windowSession(id)
+	if err != nil {
+		return nil, err
+	}
+	return wm.ListWindows(tmuxName)
+}
+
+// NewWindow opens a window in a session and returns it.
+func (m *Manager) NewWindow(id string, req WindowRequest) (*Window, error) {
+	wm, tmuxName, cwd, err := m.windowSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.CWD != "" {
+		cwd = req.CWD
+	}
+	index, err := wm.NewWindow(tmuxName, req.Name, cwd, req.Cmd)
+	if err != nil {
+		return nil, err
+	}
+	target := strconv.Itoa(index)
+	if req.Select {
+		if err := wm.SelectTarget(tmuxName, target); err != nil {
+			return nil, err
+		}
+	}
+	windows, err := wm.ListWindows(tmuxName)
+	if err != nil {
+		return nil, err
+	}
+	for i := range windows {
+		if windows[i].Index == index {
+			return &windows[i], nil
+		}
+	}
+	// The command exited right away and took the window with it
+	return nil, fmt.Errorf("window %s of session %q: %w", target, id, ErrTargetNotFound)
+}
+
+// SplitPane splits the active pane of a window and returns the new pane.
+func (m *Manager) SplitPane(id, window string, req WindowRequest) (*Pane, error) {
+	wm, tmuxName, cwd, err := m.windowSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ValidTarget(window) || strings.Contains(window, ".") {
+		return nil, ErrInvalidTarget
+	}
+	windows, err := wm.ListWindows(tmuxName)
+	if err != nil {
+		return nil, err
+	}
+	if findTarget(windows, window) == nil {
+		return nil, fmt.Errorf("window %s of session %q: %w", window, id, ErrTargetNotFound)
+	}
+	if req.CWD != "" {
+		cwd = req.CWD
+	}
+	target, err := wm.SplitPane(tmuxName, window, req.Horizontal, cwd, req.Cmd)
+	if err != nil {
+		return nil, err
+	}
+	if req.Select {
+		if err := wm.SelectTarget(tmuxName, target); err != nil {
+			return nil, err
+		}
+	}
+	if windows, err = wm.ListWindows(tmuxName); err != nil {
+		return nil, err
+	}
+	if p := findTarget(windows, target); p != nil {
+		return p, nil
+	}
+	return nil, fmt.Errorf("pane %s of session %q: %w", target, id, ErrTargetNotFound)
+}
+
+// SelectTarget makes a window or pane the active one.
+func (m *Manager) SelectTarget(id, target string) error {
+	wm, tmuxName, err := m.checkTarget(id, target)
+	if err != nil {
+		return err
+	}
+	return wm.SelectTarget(tmuxName, target)
+}
+
+// CloseTarget closes a window or pane. Closing the last pane ends the
+// session, which the monitor then reports as exited.
+func (m *Manager) CloseTarget(id, target string) error {
+	wm, tmuxName, err := m.checkTarget(id, target)
+	if err != nil {
+		return err
+	}
+	return wm.KillTarget(tmuxName, target)
+}
+
+// checkTarget verifies that target exists in a running session.
+func (m *Manager) checkTarget(id, target string) (WindowManager, string, error) {
+	if !ValidTarget(target) {
+		return nil, "", ErrInvalidTarget
+	}
+	wm, tmuxName, _, err := m.windowSession(id)
+	if err != nil {
+		return nil, "", err
+	}
+	windows, err := wm.ListWindows(tmuxName)
+	if err != nil {
+		return nil, "", err
+	}
+	if findTarget(windows, target) == nil {
+		return nil, "", fmt.Errorf("%s of session %q: %w", target, id, ErrTargetNotFound)
+	}
+	return wm, tmuxName, nil
+}
+
+// inputTarget returns what to pass to the Multiplexer input methods: the
+// session name for its active pane, or "<name>:<target>" for a specific
+// window or pane.
+func (m *Manager) inputTarget(id, tmuxName, target string) (string, error) {
+	if target == "" {
+		return tmuxName, nil
+	}
+	if _, _, err := m.checkTarget(id, target); err != nil {
+		return "", err
+	}
+	return tmuxName + ":" + target, nil
+}
+
+// findTarget returns the pane a target refers to (for a window target,
+// its active pane), or nil.
+func findTarget(windows []Window, target string) *Pane {
+	window, pane, isPane := strings.Cut(target, ".")
+	for _, w := range windows {
+		if strconv.Itoa(w.Index) != window {
+			continue
+		}
+		for i := range w.Panes {
+			p := &w.Panes[i]
+			if isPane && strconv.Itoa(p.Index) == pane || !isPane && p.Active {
+				return p
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package sessions_test
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/cc-web/internal/config"
+	"github.com/user/cc-web/internal/sessions"
+	"github.com/user/cc-web/internal/sessions/sessionstest"
+)
+
+func TestValidTarget(t *testing.T) {
+	for target, want := range map[string]bool{
+		"0": true, "12": true, "1.0": true, "3.14": true,
+		"": false, "a": false, "1.": false, ".1": false, "1.0.0": false, "1:0": false,
+	} {
+		if got := sessions.ValidTarget(target); got != want {
+			t.Errorf("sessions.ValidTarget(%q) = %v, want %v", target, got, want)
+		}
+	}
+}
+
+func TestWindows_Unsupported(t *testing.T) {
+	fake := sessionstest.NewFakeMultiplexer()
+	m := sessions.NewManagerWithMultiplexer(&config.Config{}, fake)
+	_ = fake.CreateSession("s1", "/", "", nil)
+	m.AddSession(&sessions.Session{ID: "s1", TmuxName: "s1", Status: sessions.StatusRunning})
+
+	if _, err := m.Windows("s1"); !errors.Is(err, sessions.ErrUnsupported) {
+		t.Errorf("Windows err = %v, want sessions.ErrUnsupported", err)
+	}
+	if err := m.SendText("s1", "1.0", "hi"); !errors.Is(err, sessions.ErrUnsupported) {
+		t.Errorf("SendText err = %v, want sessions.ErrUnsupported", err)
+	}
+	if err := m.SendText("s1", "x", "hi"); !errors.Is(err, sessions.ErrInvalidTarget) {
+		t.Errorf("SendText err = %v, want sessions.ErrInvalidTarget", err)
+	}
+}
+
+func TestWindows_Tmux(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Setenv("TMUX", filepath.Join(t.TempDir(), "tmux.sock")+",0,0")
+	runner := sessions.NewTmuxRunner()
+	m := sessions.NewManagerWithMultiplexer(&config.Config{}, runner)
+	dir := t.TempDir()
+	if err := runner.CreateSession("cc-win", dir, "cat", nil); err != nil {
+		t.Fatal(err)
+	}
+	defer runner.KillSession("cc-win")
+	m.AddSession(&sessions.Session{ID: "s1", TmuxName: "cc-win", CWD: dir, Status: sessions.StatusRunning})
+
+	win, err := m.NewWindow("s1", sessions.WindowRequest{Name: "watch", Cmd: "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if win.Name != "watch" || win.Active || len(win.Panes) != 1 {
+		t.Errorf("new window = %+v", win)
+	}
+	target := win.Panes[0].Target
+
+	pane, err := m.SplitPane("s1", target[:strings.Index(target, ".")], sessions.WindowRequest{Cmd: "cat", Horizontal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows, err := m.Windows("s1")
+	if err != nil || len(windows) != 2 || len(windows[1].Panes) != 2 {
+		t.Fatalf("Windows = %+v, %v", windows, err)
+	}
+
+	if err := m.SendText("s1", pane.Target, "to-the-split"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		screen, err := runner.CapturePane("cc-win:"+pane.Target, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(screen, "to-the-split") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("text not in target pane:\n%s", screen)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if main, _ := runner.CapturePane("cc-win", false); strings.Contains(main, "to-the-split") {
+		t.Error("text leaked into the active pane")
+	}
+
+	if err := m.SelectTarget("s1", pane.Target); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.CloseTarget("s1", "9"); !errors.Is(err, sessions.ErrTargetNotFound) {
+		t.Errorf("CloseTarget(9) err = %v, want sessions.ErrTargetNotFound", err)
+	}
+	if err := m.CloseTarget("s1", target[:strings.Index(target, ".")]); err != nil {
+		t.Fatal(err)
+	}
+	if windows, _ := m.Windows("s1"); len(windows) != 1 {
+		t.Errorf("windows after close = %+v", windows)
+	}
+}
@@ -0,0 +1,284 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/user/cc-web/internal/config"
+	"github.com/user/cc-web/internal/sessions"
+)
+
+// Headers set on every webhook request.
+const (
+	HeaderSignature = "X-CC-Web-Signature" // see Sign
+	HeaderTimestamp = "X-CC-Web-Timestamp" // Unix seconds, covered by the signature
+	HeaderEvent     = "X-CC-Web-Event"
+	HeaderDelivery  = "X-CC-Web-Delivery"
+)
+
+const (
+	maxAttempts  = 5
+	maxLogSize   = 200
+	queueSize    = 100
+	firstBackoff = time.Second
+	maxBackoff   = time.Minute
+)
+
+// Payload is the JSON body POSTed to webhook URLs.
+type Payload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	Time      time.Time   `json:"time"`
+	SessionID string      `json:"session_id"`
+	Session   SessionView `json:"session"`
+	Previous  string      `json:"previous,omitempty"`
+}
+
+// SessionView is the part of a session sent in webhook payloads. It leaves
+// out the final screen, env and terminal details, which may hold secrets
+// and are available from the API.
+type SessionView struct {
+	ID         string              `json:"id"`
+	Name       string              `json:"name"`
+	CWD        string              `json:"cwd"`
+	Status     sessions.Status     `json:"status"`
+	AgentState sessions.AgentState `json:"agent_state"`
+	Tags       []string            `json:"tags,omitempty"`
+	Template   string              `json:"template,omitempty"`
+	CreatedAt  time.Time           `json:"created_at"`
+	ExitCode   *int                `json:"exit_code,omitempty"`
+	ExitedAt   *time.Time          `json:"exited_at,omitempty"`
+}
+
+func newSessionView(s sessions.Session) SessionView {
+	return SessionView{
+		ID:         s.ID,
+		Name:       s.Name,
+		CWD:        s.CWD,
+		Status:     s.Status,
+		AgentState: s.AgentState,
+		Tags:       s.Tags,
+		Template:   s.Template,
+		CreatedAt:  s.CreatedAt,
+		ExitCode:   s.ExitCode,
+		ExitedAt:   s.ExitedAt,
+	}
+}
+
+// Delivery records the outcome of sending one event to one webhook.
+type Delivery struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	Event      string    `json:"event"`
+	SessionID  string    `json:"session_id"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Delivered  bool      `json:"delivered"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type job struct {
+	delivery *Delivery
+	body     []byte
+}
+
+type hook struct {
+	cfg    config.WebhookConfig
+	events map[string]bool // the configured events, or config.WebhookEvents
+	queue  chan job
+}
+
+// Dispatcher delivers session events to the configured webhooks.
+// Each webhook has its own worker, so deliveries to one URL stay in order
+// and a slow or failing endpoint does not hold up the others.
+type Dispatcher struct {
+	hooks  []*hook
+	client *http.Client
+
+	mu  sync.Mutex
+	log []*Delivery // oldest first, capped at maxLogSize
+
+	// backoff returns the wait before retry number attempt (1-based).
+	backoff func(attempt int) time.Duration
+}
+
+// NewDispatcher starts one delivery worker per configured webhook.
+func NewDispatcher(hooks []config.WebhookConfig) *Dispatcher {
+	d := &Dispatcher{
+		client:  &http.Client{Timeout: 10 * time.Second},
+		backoff: exponentialBackoff,
+	}
+	for _, c := range hooks {
+		h := &hook{cfg: c, events: make(map[string]bool), queue: make(chan job, queueSize)}
+		// Without a filter a hook gets the lifecycle events, not the
+		// frequent ones like input_sent it could not subscribe to anyway
+		events := c.Events
+		if len(events) == 0 {
+			events = config.WebhookEvents
+		}
+		for _, e := range events {
+			h.events[e] = true
+		}
+		d.hooks = append(d.hooks, h)
+		go d.run(h)
+	}
+	return d
+}
+
+// Handle queues an event for every webhook whose filter matches.
+// It never blocks; it is meant to be passed to sessions.Manager.Subscribe.
+func (d *Dispatcher) Handle(ev sessions.Event) {
+	for _, h := range d.hooks {
+		if !h.events[string(ev.Type)] {
+			continue
+		}
+		del := &Delivery{
+			ID:        newDeliveryID(),
+			URL:       h.cfg.URL,
+			Event:     string(ev.Type),
+			SessionID: ev.SessionID,
+			CreatedAt: time.Now(),
+		}
+		del.UpdatedAt = del.CreatedAt
+		body, err := json.Marshal(Payload{
+			ID:        del.ID,
+			Event:     del.Event,
+			Time:      ev.Time,
+			SessionID: ev.SessionID,
+			Session:   newSessionView(ev.Session),
+			Previous:  ev.Previous,
+		})
+		if err != nil {
+			log.Printf("webhooks: marshal %s event: %v", ev.Type, err)
+			continue
+		}
+		d.record(del)
+
+		select {
+		case h.queue <- job{delivery: del, body: body}:
+		default:
+			d.update(del, func(del *Delivery) { del.Error = "queue full; dropped" })
+			log.Printf("webhooks: queue full for %s, dropping %s event", h.cfg.URL, ev.Type)
+		}
+	}
+}
+
+// Deliveries returns the delivery log, newest first.
+func (d *Dispatcher) Deliveries() []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result := make([]Delivery, 0, len(d.log))
+	for i := len(d.log) - 1; i >= 0; i-- {
+		result = append(result, *d.log[i])
+	}
+	return result
+}
+
+func (d *Dispatcher) run(h *hook) {
+	for j := range h.queue {
+		for attempt := 1; attempt <= maxAttempts; attempt++ {
+			status, err := d.send(h, j)
+			retry := err != nil || status == http.StatusRequestTimeout ||
+				status == http.StatusTooManyRequests || status >= 500
+			d.update(j.delivery, func(del *Delivery) {
+				del.Attempts = attempt
+				del.StatusCode = status
+				del.Delivered = err == nil && status >= 200 && status < 300
+				del.Error = ""
+				if err != nil {
+					del.Error = err.Error()
+				} else if !del.Delivered {
+					del.Error = fmt.Sprintf("unexpected status %d", status)
+				}
+			})
+			if !retry || attempt == maxAttempts {
+				break
+			}
+			time.Sleep(d.backoff(attempt))
+		}
+	}
+}
+
+// send makes one delivery attempt and returns the response status.
+func (d *Dispatcher) send(h *hook, j job) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.cfg.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cc-web-webhooks")
+	req.Header.Set(HeaderEvent, j.delivery.Event)
+	req.Header.Set(HeaderDelivery, j.delivery.ID)
+	if h.cfg.Secret != "" {
+		// Stamped per attempt, so receivers can reject old requests replayed
+		timestamp := time.Now().Unix()
+		req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(HeaderSignature, Sign(h.cfg.Secret, timestamp, j.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) record(del *Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, del)
+	if len(d.log) > maxLogSize {
+		d.log = d.log[len(d.log)-maxLogSize:]
+	}
+}
+
+// update mutates a logged delivery under the lock.
+func (d *Dispatcher) update(del *Delivery, fn func(*Delivery)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(del)
+	del.UpdatedAt = time.Now()
+}
+
+// Sign returns the signature header value for a request: "sha256="
+// followed by the hex HMAC-SHA256, keyed with secret, of the timestamp
+// header value, a ".", and the raw body. Receivers should recompute it,
+// compare with hmac.Equal, and reject timestamps more than a few minutes
+// old.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func exponentialBackoff(attempt int) time.Duration {
+	b := firstBackoff << (attempt - 1)
+	if b > maxBackoff || b <= 0 {
+		return maxBackoff
+	}
+	return b
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
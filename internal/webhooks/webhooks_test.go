@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/cc-web/internal/config"
+	"github.com/user/cc-web/internal/sessions"
+)
+
+func testEvent(typ sessions.EventType) sessions.Event {
+	return sessions.Event{
+		Type:      typ,
+		SessionID: "abc123",
+		Session: sessions.Session{
+			ID: "abc123", Name: "test", FinalScreen: "token=secret",
+			Env: map[string]string{"API_KEY": "secret"},
+		},
+		Time: time.Now(),
+	}
+}
+
+// waitDeliveries polls until the newest delivery is finished or times out.
+func waitDeliveries(t *testing.T, d *Dispatcher, want int, done func(Delivery) bool) []Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		list := d.Deliveries()
+		if len(list) == want && done(list[0]) {
+			return list
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("deliveries did not settle: %+v", d.Deliveries())
+	return nil
+}
+
+func TestDispatcher_SignsPayload(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{r.Header.Clone(), body}
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher([]config.WebhookConfig{{URL: srv.URL, Secret: "s3cret"}})
+	d.Handle(testEvent(sessions.EventCreated))
+
+	var rec received
+	select {
+	case rec = <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+
+	timestamp, err := strconv.ParseInt(rec.header.Get(HeaderTimestamp), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)) > time.Minute {
+		t.Errorf("timestamp header = %q", rec.header.Get(HeaderTimestamp))
+	}
+	if sig := rec.header.Get(HeaderSignature); sig != Sign("s3cret", timestamp, rec.body) {
+		t.Errorf("signature = %q, want %q", sig, Sign("s3cret", timestamp, rec.body))
+	}
+	if strings.Contains(string(rec.body), "secret") {
+		t.Errorf("payload includes the final screen or env: %s", rec.body)
+	}
+	if ev := rec.header.Get(HeaderEvent); ev != "created" {
+		t.Errorf("event header = %q", ev)
+	}
+	var p Payload
+	if err := json.Unmarshal(rec.body, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Event != "created" || p.SessionID != "abc123" || p.Session.Name != "test" {
+		t.Errorf("payload = %+v", p)
+	}
+	if p.ID != rec.header.Get(HeaderDelivery) {
+		t.Errorf("payload id %q != delivery header %q", p.ID, rec.header.Get(HeaderDelivery))
+	}
+
+	list := waitDeliveries(t, d, 1, func(del Delivery) bool { return del.Delivered })
+	if list[0].Attempts != 1 || list[0].StatusCode != http.StatusOK {
+		t.Errorf("delivery = %+v", list[0])
+	}
+}
+
+func TestDispatcher_RetriesServerErrors(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(nil)
+	d.backoff = func(int) time.Duration { return time.Millisecond }
+	h := &hook{cfg: config.WebhookConfig{URL: srv.URL}, events: map[string]bool{"exited": true}, queue: make(chan job, 1)}
+	d.hooks = append(d.hooks, h)
+	go d.run(h)
+
+	d.Handle(testEvent(sessions.EventExited))
+	list := waitDeliveries(t, d, 1, func(del Delivery) bool { return del.Delivered })
+	if list[0].Attempts != 3 {
+		t.Errorf("attempts = %d, want 3", list[0].Attempts)
+	}
+}
+
+func TestDispatcher_NoRetryOnClientError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher([]config.WebhookConfig{{URL: srv.URL}})
+	d.Handle(testEvent(sessions.EventKilled))
+	list := waitDeliveries(t, d, 1, func(del Delivery) bool { return del.Attempts > 0 })
+	if list[0].Delivered || list[0].Attempts != 1 || list[0].StatusCode != http.StatusNotFound {
+		t.Errorf("delivery = %+v", list[0])
+	}
+}
+
+func TestDispatcher_EventFilter(t *testing.T) {
+	d := NewDispatcher([]config.WebhookConfig{{URL: "http://127.0.0.1:1", Events: []string{"exited"}}})
+	d.backoff = func(int) time.Duration { return time.Millisecond }
+	d.Handle(testEvent(sessions.EventCreated))
+	if n := len(d.Deliveries()); n != 0 {
+		t.Errorf("filtered event produced %d deliveries", n)
+	}
+
+	// Without a filter, only the subscribable event types are sent
+	d = NewDispatcher([]config.WebhookConfig{{URL: "http://127.0.0.1:1"}})
+	d.backoff = func(int) time.Duration { return time.Millisecond }
+	d.Handle(testEvent(sessions.EventInputSent))
+	d.Handle(testEvent(sessions.EventStatusChanged))
+	if n := len(d.Deliveries()); n != 0 {
+		t.Errorf("unlisted events produced %d deliveries", n)
+	}
+}
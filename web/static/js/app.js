@@ -8,6 +8,8 @@
   let currentSessionId = null;
   let currentView = 'login'; // login | sessions | session
   let filterStatus = 'all'; // all | running | exited
+  let filterTag = '';
+  let templates = [];
 
   // --- API ---
   const api = {
@@ -27,23 +29,34 @@
       return resp;
     },
 
-    async listSessions() {
-      const resp = await this.fetch('/api/sessions');
+    async listSessions(query = {}) {
+      const params = new URLSearchParams();
+      for (const [k, v] of Object.entries(query)) {
+        if (v) params.set(k, v);
+      }
+      const resp = await this.fetch(`/api/sessions?${params}`);
       const data = await resp.json();
       if (!resp.ok) throw new Error(data.error || 'Failed to list sessions');
       return data;
     },
 
-    async createSession(name, cwd, startCmd) {
+    async createSession(name, cwd, startCmd, tags, template) {
       const resp = await this.fetch('/api/sessions', {
         method: 'POST',
-        body: JSON.stringify({ name, cwd, start_cmd: startCmd }),
+        body: JSON.stringify({ name, cwd, start_cmd: startCmd, tags, template }),
       });
       const data = await resp.json();
       if (!resp.ok) throw new Error(data.error || 'Failed to create session');
       return data;
     },
 
+    async listTemplates() {
+      const resp = await this.fetch('/api/templates');
+      const data = await resp.json();
+      if (!resp.ok) throw new Error(data.error || 'Failed to list templates');
+      return data;
+    },
+
     async getSession(id) {
       const resp = await this.fetch(`/api/sessions/${id}`);
       const data = await resp.json();
@@ -51,6 +64,23 @@
       return data;
     },
 
+    async updateSession(id, fields) {
+      const resp = await this.fetch(`/api/sessions/${id}`, {
+        method: 'PATCH',
+        body: JSON.stringify(fields),
+      });
+      const data = await resp.json();
+      if (!resp.ok) throw new Error(data.error || 'Failed to update session');
+      return data;
+    },
+
+    async restartSession(id) {
+      const resp = await this.fetch(`/api/sessions/${id}/restart`, { method: 'POST' });
+      const data = await resp.json();
+      if (!resp.ok) throw new Error(data.error || 'Failed to restart session');
+      return data;
+    },
+
     async killSession(id) {
       const resp = await this.fetch(`/api/sessions/${id}/kill`, { method: 'POST' });
       const data = await resp.json();
@@ -68,6 +98,38 @@
       return data;
     },
 
+    async paste(id, text, enter) {
+      const resp = await this.fetch(`/api/sessions/${id}/paste`, {
+        method: 'POST',
+        body: JSON.stringify({ text, enter }),
+      });
+      const data = await resp.json();
+      if (!resp.ok) {
+        const err = new Error(data.error || 'Failed to paste text');
+        err.status = resp.status;
+        throw err;
+      }
+      return data;
+    },
+
+    async upload(id, files) {
+      const form = new FormData();
+      for (const f of files) form.append('file', f);
+      // Not this.fetch: the browser must set the multipart Content-Type
+      const resp = await fetch(`/api/sessions/${id}/upload`, {
+        method: 'POST',
+        headers: { 'Authorization': `Bearer ${authToken}` },
+        body: form,
+      });
+      if (resp.status === 401) {
+        logout();
+        throw new Error('Unauthorized');
+      }
+      const data = await resp.json();
+      if (!resp.ok) throw new Error(data.error || 'Failed to upload');
+      return data;
+    },
+
     async interrupt(id) {
       const resp = await this.fetch(`/api/sessions/${id}/interrupt`, { method: 'POST' });
       const data = await resp.json();
@@ -106,6 +168,7 @@
     localStorage.removeItem('cc_auth_token');
     // Clear auth cookie (set expired)
     document.cookie = 'auth_token=;path=/;expires=Thu, 01 Jan 1970 00:00:00 GMT';
+    disconnectEvents();
     showView('login');
   }
 
@@ -122,6 +185,7 @@
     setAuthCookie(token);
     showView('sessions');
     refreshSessions();
+    connectEvents();
   }
 
   // --- Views ---
@@ -142,7 +206,13 @@
   // --- Sessions List ---
   async function refreshSessions() {
     try {
-      sessions = await api.listSessions();
+      // Filtering and ordering are done by the server
+      sessions = await api.listSessions({
+        status: filterStatus === 'all' ? '' : filterStatus,
+        tag: filterTag,
+        q: $('#search-input').value.trim(),
+        sort: 'created_at',
+      });
       renderSessions();
     } catch (e) {
       if (e.message !== 'Unauthorized') {
@@ -153,35 +223,44 @@
 
   function renderSessions() {
     const list = $('#sessions-list');
-    const search = $('#search-input').value.toLowerCase();
+    const filtered = filterStatus !== 'all' || filterTag || $('#search-input').value.trim();
 
-    const filtered = sessions.filter(s => {
-      if (filterStatus !== 'all' && s.status !== filterStatus) return false;
-      if (search && !s.name.toLowerCase().includes(search) && !s.id.toLowerCase().includes(search)) return false;
-      return true;
-    });
+    // Pinned sessions first; sort is stable, so the server's order is kept otherwise
+    const shown = [...sessions].sort((a, b) => (b.pinned ? 1 : 0) - (a.pinned ? 1 : 0));
+
+    const tagBar = $('#tag-filter');
+    tagBar.style.display = filterTag ? 'flex' : 'none';
+    tagBar.innerHTML = filterTag
+      ? `<span class="chip active" onclick="app.filterByTag('')">#${escapeHtml(filterTag)} &times;</span>`
+      : '';
 
-    if (filtered.length === 0) {
+    if (shown.length === 0) {
       list.innerHTML = `
         <div class="empty-state">
           <h2>No sessions</h2>
-          <p>${sessions.length === 0 ? 'Create your first Claude Code session' : 'No sessions match your filter'}</p>
+          <p>${filtered ? 'No sessions match your filter' : 'Create your first Claude Code session'}</p>
         </div>`;
       return;
     }
 
-    list.innerHTML = filtered.map(s => {
+    list.innerHTML = shown.map(s => {
       const safeAttrId = escapeAttr(s.id);
       return `
-      <div class="session-card" data-id="${safeAttrId}">
+      <div class="session-card${s.pinned ? ' pinned' : ''}" data-id="${safeAttrId}"${s.color ? ` style="border-left-color: ${escapeAttr(s.color)}"` : ''}>
         <div class="session-card-header">
-          <h3>${escapeHtml(s.name || s.id)}</h3>
-          <span class="status-badge status-${escapeAttr(s.status)}">${escapeHtml(s.status)}</span>
+          <h3>${s.emoji ? `${escapeHtml(s.emoji)} ` : ''}${escapeHtml(s.name || s.id)}</h3>
+          <span class="status-badge status-${escapeAttr(s.status)}">${escapeHtml(s.status)}${s.exit_code != null ? ` (${escapeHtml(String(s.exit_code))})` : ''}</span>
         </div>
         ${s.cwd ? `<div class="cwd">${escapeHtml(s.cwd)}</div>` : ''}
+        ${s.notes ? `<div class="notes">${escapeHtml(s.notes)}</div>` : ''}
+        ${(s.tags || []).length ? `<div class="tags">${s.tags.map(t =>
+          `<span class="tag" onclick="app.filterByTag('${escapeAttr(t)}')">#${escapeHtml(t)}</span>`).join('')}</div>` : ''}
         <div class="session-card-actions">
           <button class="btn btn-primary btn-sm" onclick="app.openSession('${safeAttrId}')">Open</button>
+          <button class="btn btn-ghost btn-sm" onclick="app.editSession('${safeAttrId}')">Edit</button>
+          <button class="btn btn-ghost btn-sm" onclick="app.togglePin('${safeAttrId}')">${s.pinned ? 'Unpin' : 'Pin'}</button>
           ${s.status === 'running' ? `<button class="btn btn-ghost btn-sm" onclick="app.interruptSession('${safeAttrId}')">Interrupt</button>` : ''}
+          ${s.status === 'exited' ? `<button class="btn btn-ghost btn-sm" onclick="app.restartSession('${safeAttrId}')">Restart</button>` : ''}
           <button class="btn btn-danger btn-sm" onclick="app.killSession('${safeAttrId}')">Kill</button>
         </div>
       </div>`;
@@ -230,6 +309,47 @@
     }
   }
 
+  function filterByTag(tag) {
+    filterTag = tag;
+    refreshSessions();
+  }
+
+  async function editSession(id) {
+    const session = sessions.find(s => s.id === id);
+    if (!session) return;
+    const name = prompt('Session name', session.name || '');
+    if (name === null) return;
+    const notes = prompt('Notes', session.notes || '');
+    if (notes === null) return;
+    try {
+      await api.updateSession(id, { name, notes });
+      refreshSessions();
+    } catch (e) {
+      toast(e.message, 'error');
+    }
+  }
+
+  async function togglePin(id) {
+    const session = sessions.find(s => s.id === id);
+    if (!session) return;
+    try {
+      await api.updateSession(id, { pinned: !session.pinned });
+      refreshSessions();
+    } catch (e) {
+      toast(e.message, 'error');
+    }
+  }
+
+  async function restartSession(id) {
+    try {
+      await api.restartSession(id);
+      toast('Session restarted', 'success');
+      refreshSessions();
+    } catch (e) {
+      toast(e.message, 'error');
+    }
+  }
+
   async function killSession(id) {
     if (!confirm('Kill this session?')) return;
     try {
@@ -248,7 +368,18 @@
   async function sendText(text) {
     if (!currentSessionId || !text) return;
     try {
-      await api.sendText(currentSessionId, text);
+      // Multi-line text goes in as one bracketed paste so it is submitted
+      // once, not line by line; backends without paste get plain send.
+      if (text.includes('\n')) {
+        try {
+          await api.paste(currentSessionId, text, true);
+        } catch (e) {
+          if (e.status !== 501) throw e;
+          await api.sendText(currentSessionId, text);
+        }
+      } else {
+        await api.sendText(currentSessionId, text);
+      }
       toast('Sent', 'success');
     } catch (e) {
       toast(e.message, 'error');
@@ -270,8 +401,33 @@
     $('#create-name').value = '';
     $('#create-cwd').value = '';
     $('#create-cmd').value = 'claude';
+    $('#create-tags').value = '';
+    $('#create-template').value = '';
     $('#create-error').style.display = 'none';
     $('#create-name').focus();
+    loadTemplates();
+  }
+
+  async function loadTemplates() {
+    try {
+      templates = await api.listTemplates();
+    } catch (e) {
+      templates = [];
+    }
+    const select = $('#create-template');
+    select.innerHTML = '<option value="">None</option>' + templates.map(t =>
+      `<option value="${escapeAttr(t.name)}">${escapeHtml(t.name)}</option>`).join('');
+    $('#create-template-group').style.display = templates.length ? 'block' : 'none';
+  }
+
+  // Fills the form from a template; edited fields override it on create
+  function applyTemplate(name) {
+    const t = templates.find(t => t.name === name);
+    if (!t) return;
+    $('#create-name').value = t.name;
+    $('#create-cwd').value = t.cwd;
+    $('#create-cmd').value = t.start_cmd || 'claude';
+    $('#create-tags').value = (t.tags || []).join(' ');
   }
 
   function hideCreateModal() {
@@ -282,6 +438,8 @@
     const name = $('#create-name').value.trim();
     const cwd = $('#create-cwd').value.trim();
     const cmd = $('#create-cmd').value.trim() || 'claude';
+    const tags = $('#create-tags').value.split(/[\s,]+/).filter(Boolean);
+    const template = $('#create-template').value;
 
     if (!name) {
       showFormError('Name is required');
@@ -297,7 +455,7 @@
     btn.innerHTML = '<span class="spinner"></span> Creating...';
 
     try {
-      const session = await api.createSession(name, cwd, cmd);
+      const session = await api.createSession(name, cwd, cmd, tags, template);
       hideCreateModal();
       toast('Session created', 'success');
       await refreshSessions();
@@ -337,6 +495,20 @@
     hideIntervene();
   }
 
+  // Uploads the picked files and adds their paths to the message being written.
+  async function attachFiles(files) {
+    if (!currentSessionId || !files.length) return;
+    try {
+      const uploads = await api.upload(currentSessionId, files);
+      const textarea = $('#intervene-text');
+      const paths = uploads.map(u => u.path).join(' ');
+      textarea.value = textarea.value.trim() ? `${textarea.value.trim()} ${paths}` : paths;
+      toast(`Uploaded ${uploads.length} file${uploads.length === 1 ? '' : 's'}`, 'success');
+    } catch (e) {
+      toast(e.message, 'error');
+    }
+  }
+
   function sendMacro(text) {
     sendText(text);
     hideIntervene();
@@ -367,8 +539,12 @@
       if (token) login(token);
     });
 
-    // Search
-    $('#search-input').addEventListener('input', renderSessions);
+    // Search, debounced since it queries the server
+    let searchTimer = null;
+    $('#search-input').addEventListener('input', () => {
+      clearTimeout(searchTimer);
+      searchTimer = setTimeout(refreshSessions, 250);
+    });
 
     // Filter chips
     $$('.chip[data-filter]').forEach(chip => {
@@ -376,7 +552,7 @@
         filterStatus = chip.dataset.filter;
         $$('.chip[data-filter]').forEach(c => c.classList.remove('active'));
         chip.classList.add('active');
-        renderSessions();
+        refreshSessions();
       });
     });
 
@@ -384,6 +560,7 @@
     $('#new-session-btn').addEventListener('click', showCreateModal);
     $('#create-cancel').addEventListener('click', hideCreateModal);
     $('#create-submit').addEventListener('click', createSession);
+    $('#create-template').addEventListener('change', (e) => applyTemplate(e.target.value));
     $('#create-modal').addEventListener('click', (e) => {
       if (e.target === e.currentTarget) hideCreateModal();
     });
@@ -416,6 +593,11 @@
 
     // Intervene send actions
     $('#intervene-send').addEventListener('click', () => intervene());
+    $('#intervene-attach').addEventListener('click', () => $('#intervene-file').click());
+    $('#intervene-file').addEventListener('change', (e) => {
+      attachFiles(Array.from(e.target.files));
+      e.target.value = '';
+    });
     $('#intervene-send-no-refactor').addEventListener('click', () => intervene(null, "Don't refactor, focus only on the task."));
     $('#intervene-send-summarize').addEventListener('click', () => intervene(null, 'Summarize your progress so far.'));
 
@@ -427,10 +609,14 @@
     // Logout
     $('#logout-btn').addEventListener('click', logout);
 
-    // Auto-refresh sessions every 5s when on sessions view
+    // Push notifications
+    $('#push-btn').addEventListener('click', enablePush);
+
+    // Refresh on server events; poll every 5s only while the feed is down
     setInterval(() => {
-      if (currentView === 'sessions' && authToken) {
+      if (currentView === 'sessions' && authToken && !eventsConnected) {
         refreshSessions();
+        connectEvents();
       }
     }, 5000);
 
@@ -439,15 +625,102 @@
       showView('sessions');
       setAuthCookie(authToken);
       refreshSessions();
+      connectEvents();
     } else {
       showView('login');
     }
   }
 
+  // --- Event feed ---
+  let eventSource = null;
+  let eventsConnected = false;
+  let eventsRefreshTimer = null;
+
+  function connectEvents() {
+    if (!window.EventSource || eventSource) return;
+    // Auth via the auth_token cookie; EventSource cannot set headers
+    const es = new EventSource('/api/events?type=created,killed,recovered,status_changed,updated,agent_state,timeout_warning');
+    eventSource = es;
+    es.onopen = () => { eventsConnected = true; };
+    es.onerror = () => {
+      eventsConnected = false;
+      // The browser retries on network errors but gives up on HTTP errors
+      if (es.readyState === EventSource.CLOSED) eventSource = null;
+    };
+    const onChange = () => {
+      if (currentView !== 'sessions' || !authToken) return;
+      // Coalesce bursts (e.g. recovery) into a single list fetch
+      clearTimeout(eventsRefreshTimer);
+      eventsRefreshTimer = setTimeout(refreshSessions, 200);
+    };
+    ['created', 'killed', 'recovered', 'status_changed', 'updated', 'agent_state', 'reset'].forEach((t) => {
+      es.addEventListener(t, onChange);
+    });
+    es.addEventListener('timeout_warning', (e) => {
+      const ev = JSON.parse(e.data);
+      const name = ev.session.name || ev.session_id;
+      toast(ev.detail === 'max_lifetime'
+        ? `${name} reached its maximum lifetime and will be stopped`
+        : `${name} is idle and will be stopped unless you use it`, 'error');
+    });
+  }
+
+  function disconnectEvents() {
+    if (eventSource) eventSource.close();
+    eventSource = null;
+    eventsConnected = false;
+  }
+
+  // --- Web Push ---
+  function urlBase64ToUint8Array(b64) {
+    const padded = (b64 + '='.repeat((4 - (b64.length % 4)) % 4)).replace(/-/g, '+').replace(/_/g, '/');
+    const raw = atob(padded);
+    return Uint8Array.from(raw, (c) => c.charCodeAt(0));
+  }
+
+  async function enablePush() {
+    if (!('serviceWorker' in navigator) || !('PushManager' in window)) {
+      toast('Push notifications are not supported in this browser', 'error');
+      return;
+    }
+    try {
+      const permission = await Notification.requestPermission();
+      if (permission !== 'granted') {
+        toast('Notifications blocked', 'error');
+        return;
+      }
+      const keyResp = await api.fetch('/api/push/vapid-public-key');
+      const keyData = await keyResp.json();
+      if (!keyResp.ok) throw new Error(keyData.error || 'Push unavailable');
+
+      const reg = await navigator.serviceWorker.ready;
+      let sub = await reg.pushManager.getSubscription();
+      if (!sub) {
+        sub = await reg.pushManager.subscribe({
+          userVisibleOnly: true,
+          applicationServerKey: urlBase64ToUint8Array(keyData.public_key),
+        });
+      }
+      const resp = await api.fetch('/api/push/subscriptions', {
+        method: 'POST',
+        body: JSON.stringify(sub.toJSON()),
+      });
+      const data = await resp.json();
+      if (!resp.ok) throw new Error(data.error || 'Failed to subscribe');
+      toast('Notifications enabled', 'success');
+    } catch (e) {
+      toast(e.message, 'error');
+    }
+  }
+
   // Expose functions for inline onclick handlers
   window.app = {
     openSession,
     interruptSession,
+    editSession,
+    togglePin,
+    filterByTag,
+    restartSession,
     killSession,
   };
 
@@ -25,3 +25,34 @@ self.addEventListener('fetch', (e) => {
     )
   );
 });
+
+// Web Push: show notifications sent by the gateway (session exited / needs input)
+self.addEventListener('push', (e) => {
+  let data = {};
+  try {
+    data = e.data ? e.data.json() : {};
+  } catch (_) {
+    data = { title: 'Claude Code', body: e.data ? e.data.text() : '' };
+  }
+  e.waitUntil(
+    self.registration.showNotification(data.title || 'Claude Code', {
+      body: data.body || '',
+      tag: data.tag,
+      icon: '/icon.svg',
+      data: { url: data.url || '/', session_id: data.session_id },
+    })
+  );
+});
+
+self.addEventListener('notificationclick', (e) => {
+  e.notification.close();
+  const url = (e.notification.data && e.notification.data.url) || '/';
+  e.waitUntil(
+    self.clients.matchAll({ type: 'window', includeUncontrolled: true }).then((list) => {
+      for (const c of list) {
+        if ('focus' in c) return c.focus();
+      }
+      return self.clients.openWindow(url);
+    })
+  );
+});